@@ -30,6 +30,15 @@ type ClearSendChan interface {
 	ClearSendChan(<-chan interface{}) //接收一个读的chan
 }
 
+//可以设置读写超时的Codec，net.Conn也实现了这两个方法
+//底层连接不支持超时的时候返回错误
+//Receive被读超时中断时，如果还没有读取任何数据就返回满足errors.Is(err, os.ErrDeadlineExceeded)的错误，Codec可以继续使用
+//已经读取了部分数据的时候需要返回其它错误
+type DeadlineCodec interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
 //新建一个server
 func Listen(network, address string, protocol Protocol, sendChanSize int, handler Handler) (*Server, error) {
 	listener, err := net.Listen(network, address)
@@ -39,6 +48,15 @@ func Listen(network, address string, protocol Protocol, sendChanSize int, handle
 	return NewServer(listener, protocol, sendChanSize, handler), nil
 }
 
+//根据参数新建一个server
+func ListenWithOptions(network, address string, protocol Protocol, handler Handler, options ServerOptions) (*Server, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewServerWithOptions(listener, protocol, handler, options), nil
+}
+
 //新建一个session
 func Dial(network, address string, protocol Protocol, sendChanSize int) (*Session, error) {
 	conn, err := net.Dial(network, address)
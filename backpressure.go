@@ -0,0 +1,76 @@
+package link
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+//异步发送队列满时的处理策略
+type BackPressure int
+
+const (
+	BackPressureClose      BackPressure = iota //关闭session并返回SessionBlockedError，默认策略
+	BackPressureBlock                          //等待队列空出位置，超时后按BackPressureClose处理
+	BackPressureDropNewest                     //丢弃当前要发送的消息
+	BackPressureDropOldest                     //丢弃队列中最早的消息，再放入当前的消息
+	backPressureNum
+)
+
+//设置发送队列满时的处理策略，timeout只对BackPressureBlock有效，小于等于0表示一直等待
+//需要在开始发送消息之前设置
+func (session *Session) SetBackPressure(policy BackPressure, timeout time.Duration) {
+	session.backPressure = policy
+	session.blockTimeout = timeout
+}
+
+//获取在某种策略下被丢弃的消息数量
+func (session *Session) DropCount(policy BackPressure) uint64 {
+	if policy < 0 || policy >= backPressureNum {
+		return 0
+	}
+	return atomic.LoadUint64(&session.dropCounts[policy])
+}
+
+//发送队列满时按照策略处理，调用时需要持有sendMutex的读锁
+//BackPressureBlock等待时ctx结束会放弃发送并返回ctx的错误
+func (session *Session) sendBlocked(ctx context.Context, msg interface{}) error {
+	switch session.backPressure {
+	case BackPressureBlock:
+		var timeout <-chan time.Time
+		if session.blockTimeout > 0 {
+			timer := time.NewTimer(session.blockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case session.sendChan <- msg:
+			return nil
+		case <-session.closeChan:
+			return SessionClosedError
+		case <-session.stopChan: //CloseWithDrain需要加写锁
+			return SessionClosedError
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+		}
+	case BackPressureDropNewest:
+		atomic.AddUint64(&session.dropCounts[BackPressureDropNewest], 1)
+		return nil
+	case BackPressureDropOldest:
+		for {
+			select {
+			case <-session.sendChan:
+				atomic.AddUint64(&session.dropCounts[BackPressureDropOldest], 1)
+			default:
+			}
+			select {
+			case session.sendChan <- msg:
+				return nil
+			default:
+			}
+		}
+	}
+	atomic.AddUint64(&session.dropCounts[session.backPressure], 1)
+	return SessionBlockedError
+}
@@ -0,0 +1,136 @@
+package link
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+//广播的结果
+type BroadcastResult[K comparable] struct {
+	Sent   int         //发送成功的数量，按照背压策略丢弃的消息也算发送成功
+	Failed map[K]error //发送失败的key和错误，发送队列阻塞时错误是SessionBlockedError，超时的时候是ctx的错误
+}
+
+//发送队列阻塞或者发送超时的key
+func (result *BroadcastResult[K]) Blocked() []K {
+	var keys []K
+	for key, err := range result.Failed {
+		if err == SessionBlockedError || err == context.DeadlineExceeded || err == context.Canceled {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+//把消息发送给所有的session，每个session一个goroutine
+//会等待所有的Send返回，同步发送的session卡住时请使用BroadcastContext
+func (channel *TypedChannel[K]) Broadcast(msg interface{}) *BroadcastResult[K] {
+	return channel.BroadcastContext(context.Background(), msg, 0)
+}
+
+//把消息发送给所有的session，最多同时使用workers个goroutine，小于等于0时不限制
+func (channel *TypedChannel[K]) BroadcastN(msg interface{}, workers int) *BroadcastResult[K] {
+	return channel.BroadcastContext(context.Background(), msg, workers)
+}
+
+//把消息发送给所有的session，最多同时使用workers个goroutine，小于等于0时不限制
+//发送前先复制session列表，发送时不持有channel的锁，单个session的阻塞不会影响其它session
+//异步发送的session按照各自的背压策略处理，ctx只限制同步发送和BackPressureBlock的等待
+//ctx结束时立即返回，还没有发送完的key作为阻塞的key返回，同步发送被中断的session会被关闭
+func (channel *TypedChannel[K]) BroadcastContext(ctx context.Context, msg interface{}, workers int) *BroadcastResult[K] {
+	channel.mutex.RLock()
+	keys := make(map[*Session]K, len(channel.sessions))
+	sessions := make([]*Session, 0, len(channel.sessions))
+	for key, session := range channel.sessions {
+		keys[session] = key
+		sessions = append(sessions, session)
+	}
+	channel.mutex.RUnlock()
+
+	return broadcast(ctx, sessions, msg, workers, func(session *Session) K {
+		return keys[session]
+	})
+}
+
+//把消息发送给sessions，key用于获取结果中session对应的key
+//ctx结束时不再等待还没完成的发送
+func broadcast[K comparable](ctx context.Context, sessions []*Session, msg interface{}, workers int, key func(*Session) K) *BroadcastResult[K] {
+	var mutex sync.Mutex
+	var returned bool
+	errs := make([]error, len(sessions))
+	finished := make([]bool, len(sessions))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fanout(len(sessions), workers, func(i int) {
+			err := sessions[i].broadcastSend(ctx, msg)
+			mutex.Lock()
+			if !returned {
+				errs[i] = err
+				finished[i] = true
+			}
+			mutex.Unlock()
+		})
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mutex.Lock()
+	returned = true
+	mutex.Unlock()
+
+	result := &BroadcastResult[K]{}
+	for i, err := range errs {
+		if !finished[i] {
+			err = ctx.Err()
+		}
+		if err == nil {
+			result.Sent++
+			continue
+		}
+		if result.Failed == nil {
+			result.Failed = make(map[K]error)
+		}
+		result.Failed[key(sessions[i])] = err
+	}
+	return result
+}
+
+//广播时发送消息，同步发送时用SendContext，异步发送时和Send一样按照背压策略处理
+func (session *Session) broadcastSend(ctx context.Context, msg interface{}) error {
+	if session.sendChan == nil {
+		if ctx.Done() == nil {
+			return session.Send(msg)
+		}
+		return session.SendContext(ctx, msg)
+	}
+	return session.sendAsync(ctx, msg)
+}
+
+//用最多workers个goroutine对0到n-1调用send，等待全部完成
+func fanout(n, workers int, send func(i int)) {
+	if workers <= 0 || workers > n {
+		workers = n
+	}
+
+	var next int64 = -1
+	var wait sync.WaitGroup
+	wait.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wait.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				send(i)
+			}
+		}()
+	}
+	wait.Wait()
+}
@@ -0,0 +1,146 @@
+package link
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+func Test_Broadcast(t *testing.T) {
+	channel := NewTypedChannel[int]()
+
+	codecs := make([]*BlockingCodec, 10)
+	for i := 0; i < len(codecs); i++ {
+		codecs[i] = newBlockingCodec()
+		if i != 0 {
+			close(codecs[i].release)
+		}
+		channel.Put(i, NewSession(codecs[i], 0))
+	}
+
+	//第一个session同步发送阻塞，不影响其它session
+	done := make(chan *BroadcastResult[int])
+	go func() {
+		done <- channel.BroadcastN(1, 3)
+	}()
+	for i := 1; i < len(codecs); i++ {
+		utest.EqualNow(t, <-codecs[i].sent, 1)
+	}
+	utest.EqualNow(t, channel.Len(), 10)
+
+	close(codecs[0].release)
+	result := <-done
+	utest.EqualNow(t, result.Sent, 10)
+	utest.EqualNow(t, len(result.Failed), 0)
+
+	//异步发送队列阻塞
+	blocked := newBlockingCodec()
+	session := NewSession(blocked, 1)
+	channel.Put(100, session)
+	session.Send(0)
+	for len(session.sendChan) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	session.Send(0)
+
+	result = channel.Broadcast(2)
+	utest.EqualNow(t, result.Sent, 10)
+	utest.EqualNow(t, result.Failed[100], SessionBlockedError)
+	utest.Assert(t, reflect.DeepEqual(result.Blocked(), []int{100}))
+	close(blocked.release)
+}
+
+func Test_BroadcastContext(t *testing.T) {
+	channel := NewTypedChannel[int]()
+
+	//前两个session同步发送一直阻塞
+	codecs := make([]*BlockingCodec, 10)
+	for i := 0; i < len(codecs); i++ {
+		codecs[i] = newBlockingCodec()
+		if i >= 2 {
+			close(codecs[i].release)
+		}
+		channel.Put(i, NewSession(codecs[i], 0))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := channel.BroadcastContext(ctx, 1, 3)
+	utest.Assert(t, time.Since(start) < time.Second)
+	utest.EqualNow(t, result.Sent, 8)
+	utest.EqualNow(t, result.Failed[0], context.DeadlineExceeded)
+	utest.EqualNow(t, result.Failed[1], context.DeadlineExceeded)
+	blocked := result.Blocked()
+	sort.Ints(blocked)
+	utest.Assert(t, reflect.DeepEqual(blocked, []int{0, 1}))
+	for i := 2; i < len(codecs); i++ {
+		utest.EqualNow(t, <-codecs[i].sent, 1)
+	}
+
+	close(codecs[0].release)
+	close(codecs[1].release)
+
+	//只有一个worker的时候排在卡住的session后面的session也作为阻塞的session返回
+	channel = NewTypedChannel[int]()
+	stuck := newBlockingCodec()
+	channel.Put(0, NewSession(stuck, 0))
+	for i := 1; i < 4; i++ {
+		codec := newBlockingCodec()
+		close(codec.release)
+		channel.Put(i, NewSession(codec, 0))
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	result = channel.BroadcastContext(ctx, 2, 1)
+	utest.EqualNow(t, result.Failed[0], context.DeadlineExceeded)
+	utest.EqualNow(t, result.Sent+len(result.Blocked()), 4)
+	close(stuck.release)
+}
+
+func Test_BroadcastContextBackPressure(t *testing.T) {
+	channel := NewTypedChannel[int]()
+
+	//异步发送队列满的session按照背压策略丢弃消息，不等待ctx结束
+	codec := newBlockingCodec()
+	session := newSession(nil, codec, 1, BackPressureDropNewest, 0)
+	channel.Put(0, session)
+	session.Send(0)
+	for len(session.sendChan) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	session.Send(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	result := channel.BroadcastContext(ctx, 1, 0)
+	utest.Assert(t, time.Since(start) < 500*time.Millisecond)
+	utest.EqualNow(t, result.Sent, 1)
+	utest.EqualNow(t, len(result.Failed), 0)
+	utest.EqualNow(t, session.DropCount(BackPressureDropNewest), uint64(1))
+	utest.Assert(t, !session.IsClosed())
+
+	//BackPressureBlock等待时ctx结束，放弃发送但不关闭session
+	blocked := newBlockingCodec()
+	session = newSession(nil, blocked, 1, BackPressureBlock, 0)
+	channel.Put(1, session)
+	session.Send(0)
+	for len(session.sendChan) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	session.Send(0)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	result = channel.BroadcastContext(ctx, 2, 0)
+	utest.EqualNow(t, result.Failed[1], context.DeadlineExceeded)
+	utest.Assert(t, !session.IsClosed())
+
+	close(codec.release)
+	close(blocked.release)
+}
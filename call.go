@@ -0,0 +1,116 @@
+package link
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+//给消息加上或者去掉序号，Caller通过序号匹配请求和应答
+//应答要使用请求的序号，序号为0的消息是对方主动推送的消息
+//服务端收到请求后通过session.Send(envelope.Pack(seq, rsp))返回应答
+type Envelope interface {
+	Pack(seq uint64, msg interface{}) interface{}
+	Unpack(msg interface{}) (seq uint64, body interface{}, err error)
+}
+
+//基于Session的请求应答调用
+type Caller struct {
+	session  *Session
+	envelope Envelope
+	push     func(interface{}) //处理推送消息
+	seq      uint64            //请求的序号
+
+	mutex   sync.Mutex
+	pending map[uint64]chan interface{} //等待应答的请求
+}
+
+//新建一个Caller并启动接收循环，push用于处理推送消息，可以为nil
+//创建Caller之后不能再直接调用session.Receive
+func NewCaller(session *Session, envelope Envelope, push func(interface{})) *Caller {
+	caller := &Caller{
+		session:  session,
+		envelope: envelope,
+		push:     push,
+		pending:  make(map[uint64]chan interface{}),
+	}
+	go caller.receiveLoop()
+	return caller
+}
+
+//获取session
+func (caller *Caller) Session() *Session {
+	return caller.session
+}
+
+//发送请求并等待应答，ctx结束或session关闭时返回错误
+func (caller *Caller) Call(ctx context.Context, req interface{}) (interface{}, error) {
+	seq := atomic.AddUint64(&caller.seq, 1)
+	reply := make(chan interface{}, 1)
+
+	caller.mutex.Lock()
+	caller.pending[seq] = reply
+	caller.mutex.Unlock()
+
+	if err := caller.session.SendContext(ctx, caller.envelope.Pack(seq, req)); err != nil {
+		caller.forget(seq)
+		return nil, err
+	}
+
+	select {
+	case rsp := <-reply:
+		return rsp, nil
+	case <-ctx.Done():
+		caller.forget(seq)
+		return nil, ctx.Err()
+	case <-caller.session.closeChan:
+		caller.forget(seq)
+		//应答可能在session关闭前已经到达
+		select {
+		case rsp := <-reply:
+			return rsp, nil
+		default:
+		}
+		return nil, caller.session.CloseReason()
+	}
+}
+
+//放弃等待应答
+func (caller *Caller) forget(seq uint64) {
+	caller.mutex.Lock()
+	defer caller.mutex.Unlock()
+	delete(caller.pending, seq)
+}
+
+//接收循环，把应答交给等待中的请求，推送消息交给push
+func (caller *Caller) receiveLoop() {
+	for {
+		msg, err := caller.session.Receive()
+		if err != nil {
+			return
+		}
+
+		seq, body, err := caller.envelope.Unpack(msg)
+		if err != nil {
+			caller.session.CloseWithReason(err)
+			return
+		}
+
+		if seq == 0 {
+			if caller.push != nil {
+				caller.push(body)
+			}
+			continue
+		}
+
+		caller.mutex.Lock()
+		reply, exists := caller.pending[seq]
+		delete(caller.pending, seq)
+		caller.mutex.Unlock()
+
+		//已经超时的请求的应答直接丢弃
+		if exists {
+			reply <- body
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+type TestEnvelope struct{}
+
+func (TestEnvelope) Pack(seq uint64, msg interface{}) interface{} {
+	buf := make([]byte, 8+len(msg.([]byte)))
+	binary.LittleEndian.PutUint64(buf, seq)
+	copy(buf[8:], msg.([]byte))
+	return buf
+}
+
+func (TestEnvelope) Unpack(msg interface{}) (uint64, interface{}, error) {
+	buf := msg.([]byte)
+	if len(buf) < 8 {
+		return 0, nil, errors.New("bad envelope")
+	}
+	return binary.LittleEndian.Uint64(buf), buf[8:], nil
+}
+
+func Test_Call(t *testing.T) {
+	envelope := TestEnvelope{}
+	server, err := Listen("tcp", "0.0.0.0:0", ProtocolFunc(NewTestCodec), 0, HandlerFunc(func(session *Session) {
+		for {
+			msg, err := session.Receive()
+			if err != nil {
+				return
+			}
+			seq, body, _ := envelope.Unpack(msg)
+			//在应答之前插入推送消息
+			session.Send(envelope.Pack(0, []byte("push")))
+			if bytes.Equal(body.([]byte), []byte("timeout")) {
+				continue
+			}
+			session.Send(envelope.Pack(seq, body))
+		}
+	}))
+	utest.IsNilNow(t, err)
+	go server.Serve()
+
+	session, err := Dial("tcp", server.Listener().Addr().String(), ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+
+	var pushCount int
+	var pushMutex sync.Mutex
+	caller := NewCaller(session, envelope, func(msg interface{}) {
+		pushMutex.Lock()
+		pushCount++
+		pushMutex.Unlock()
+	})
+
+	wait := new(sync.WaitGroup)
+	for i := 0; i < 20; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for j := 0; j < 50; j++ {
+				req := RandBytes(128)
+				rsp, err := caller.Call(context.Background(), req)
+				utest.IsNilNow(t, err)
+				utest.Assert(t, bytes.Equal(req, rsp.([]byte)))
+			}
+		}()
+	}
+	wait.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = caller.Call(ctx, []byte("timeout"))
+	utest.EqualNow(t, err, context.DeadlineExceeded)
+
+	session.Close()
+	_, err = caller.Call(context.Background(), []byte("closed"))
+	utest.EqualNow(t, err, SessionClosedError)
+
+	pushMutex.Lock()
+	utest.Assert(t, pushCount >= 1000)
+	pushMutex.Unlock()
+
+	server.Stop()
+}
@@ -2,14 +2,52 @@ package link
 
 import (
 	"sync"
+	"time"
 )
 
 //定义一种类型
 type KEY interface{}
 
-type Channel struct {
-	mutex    sync.RWMutex     //读写锁
-	sessions map[KEY]*Session //一个map
+//兼容旧版本的Channel，key可以是任意类型
+type Channel = TypedChannel[KEY]
+
+//Channel成员变化的类型
+type ChannelEventType int
+
+const (
+	ChannelJoin    ChannelEventType = iota //新增成员
+	ChannelLeave                           //成员离开
+	ChannelReplace                         //同一个key放入了另一个session
+)
+
+//Channel成员变化的事件
+type ChannelEvent[K comparable] struct {
+	Type    ChannelEventType
+	Key     K
+	Session *Session //加入、离开或者替换进来的session
+	Old     *Session //ChannelReplace时被替换掉的session
+	Reason  error    //session关闭导致离开时是关闭原因，其它情况为nil
+}
+
+//Channel的成员
+type ChannelMember[K comparable] struct {
+	Key      K
+	Session  *Session
+	JoinedAt time.Time //加入的时间
+}
+
+//成员变化的监听者
+type channelListener[K comparable] struct {
+	callback func(ChannelEvent[K])
+}
+
+//key类型为K的Channel
+type TypedChannel[K comparable] struct {
+	mutex     sync.RWMutex          //读写锁
+	sessions  map[K]*Session        //一个map
+	joined    map[K]time.Time       //加入的时间
+	listeners []*channelListener[K] //监听者，修改时整个替换，发送事件时不需要加锁
+	internal  bool                  //由Hub管理的Channel，session的分组只记录Hub
 
 	// channel state
 	State interface{} //一个状态
@@ -17,20 +55,26 @@ type Channel struct {
 
 //新建个channel
 func NewChannel() *Channel {
-	return &Channel{
-		sessions: make(map[KEY]*Session),
+	return NewTypedChannel[KEY]()
+}
+
+//新建个key类型为K的channel
+func NewTypedChannel[K comparable]() *TypedChannel[K] {
+	return &TypedChannel[K]{
+		sessions: make(map[K]*Session),
+		joined:   make(map[K]time.Time),
 	}
 }
 
 //chan的长度
-func (channel *Channel) Len() int {
+func (channel *TypedChannel[K]) Len() int {
 	channel.mutex.RLock()
 	defer channel.mutex.RUnlock()
 	return len(channel.sessions)
 }
 
 //对所有的session调用所有的回调函数
-func (channel *Channel) Fetch(callback func(*Session)) {
+func (channel *TypedChannel[K]) Fetch(callback func(*Session)) {
 	channel.mutex.RLock()
 	defer channel.mutex.RUnlock()
 	for _, session := range channel.sessions {
@@ -39,64 +83,185 @@ func (channel *Channel) Fetch(callback func(*Session)) {
 }
 
 //获取一个session
-func (channel *Channel) Get(key KEY) *Session {
+func (channel *TypedChannel[K]) Get(key K) *Session {
 	channel.mutex.RLock()
 	defer channel.mutex.RUnlock()
 	session, _ := channel.sessions[key]
 	return session
 }
 
-//添加一个session
-func (channel *Channel) Put(key KEY, session *Session) {
+//获取所有的成员和加入的时间
+func (channel *TypedChannel[K]) Members() []ChannelMember[K] {
+	channel.mutex.RLock()
+	defer channel.mutex.RUnlock()
+	members := make([]ChannelMember[K], 0, len(channel.sessions))
+	for key, session := range channel.sessions {
+		members = append(members, ChannelMember[K]{key, session, channel.joined[key]})
+	}
+	return members
+}
+
+//获取成员加入的时间
+func (channel *TypedChannel[K]) JoinedAt(key K) (time.Time, bool) {
+	channel.mutex.RLock()
+	defer channel.mutex.RUnlock()
+	joinedAt, exists := channel.joined[key]
+	return joinedAt, exists
+}
+
+//订阅成员变化的事件，回调在修改完成并释放锁之后调用，返回的函数用于取消订阅
+func (channel *TypedChannel[K]) Subscribe(callback func(ChannelEvent[K])) (unsubscribe func()) {
+	listener := &channelListener[K]{callback}
+
 	channel.mutex.Lock()
 	defer channel.mutex.Unlock()
-	if session, exists := channel.sessions[key]; exists {
-		channel.remove(key, session)
+	channel.listeners = append(channel.listeners[:len(channel.listeners):len(channel.listeners)], listener)
+
+	return func() {
+		channel.mutex.Lock()
+		defer channel.mutex.Unlock()
+		listeners := make([]*channelListener[K], 0, len(channel.listeners))
+		for _, l := range channel.listeners {
+			if l != listener {
+				listeners = append(listeners, l)
+			}
+		}
+		channel.listeners = listeners
+	}
+}
+
+//添加一个session
+func (channel *TypedChannel[K]) Put(key K, session *Session) {
+	channel.mutex.Lock()
+	old, exists := channel.sessions[key]
+	if exists {
+		channel.remove(key, old)
 	}
 	//调用关闭的回调函数
-	session.AddCloseCallback(channel, key, func() {
-		channel.Remove(key)
+	session.AddCloseCallback(channel, key, func(reason error) {
+		channel.removeClosed(key, session, reason)
 	})
-	//放入session
+	//放入session，重复放入同一个session不算新加入
 	channel.sessions[key] = session
+	if !channel.internal {
+		session.addMembership(channel, key)
+	}
+	if old != session {
+		channel.joined[key] = time.Now()
+	}
+	listeners := channel.listeners
+	channel.mutex.Unlock()
+
+	switch {
+	case !exists:
+		channel.emit(listeners, ChannelEvent[K]{Type: ChannelJoin, Key: key, Session: session})
+	case old != session:
+		channel.emit(listeners, ChannelEvent[K]{Type: ChannelReplace, Key: key, Session: session, Old: old})
+	}
 }
 
 //调用移除的回调函数，删除对应的session
-func (channel *Channel) remove(key KEY, session *Session) {
+func (channel *TypedChannel[K]) remove(key K, session *Session) {
 	//移除和关闭时的回调
 	session.RemoveCloseCallback(channel, key)
+	if !channel.internal {
+		session.removeMembership(channel, key)
+	}
 	delete(channel.sessions, key)
 }
 
+//session还在channel中时删除它，用于Session.LeaveAll
+func (channel *TypedChannel[K]) leave(key interface{}, session *Session) bool {
+	k := key.(K)
+	channel.mutex.Lock()
+	if channel.sessions[k] != session {
+		channel.mutex.Unlock()
+		return false
+	}
+	channel.remove(k, session)
+	delete(channel.joined, k)
+	listeners := channel.listeners
+	channel.mutex.Unlock()
+
+	channel.emit(listeners, ChannelEvent[K]{Type: ChannelLeave, Key: k, Session: session})
+	return true
+}
+
+//session关闭时删除对应的session
+func (channel *TypedChannel[K]) removeClosed(key K, session *Session, reason error) {
+	channel.mutex.Lock()
+	if channel.sessions[key] != session {
+		channel.mutex.Unlock()
+		return
+	}
+	channel.remove(key, session)
+	delete(channel.joined, key)
+	listeners := channel.listeners
+	channel.mutex.Unlock()
+
+	channel.emit(listeners, ChannelEvent[K]{Type: ChannelLeave, Key: key, Session: session, Reason: reason})
+}
+
 //删除对应的session
-func (channel *Channel) Remove(key KEY) bool {
+func (channel *TypedChannel[K]) Remove(key K) bool {
 	channel.mutex.Lock()
-	defer channel.mutex.Unlock()
 	session, exists := channel.sessions[key]
 	if exists {
 		channel.remove(key, session)
+		delete(channel.joined, key)
+	}
+	listeners := channel.listeners
+	channel.mutex.Unlock()
+
+	if exists {
+		channel.emit(listeners, ChannelEvent[K]{Type: ChannelLeave, Key: key, Session: session})
 	}
 	return exists
 }
 
 //获取一个连接，并且把它删除
-func (channel *Channel) FetchAndRemove(callback func(*Session)) {
+func (channel *TypedChannel[K]) FetchAndRemove(callback func(*Session)) {
 	channel.mutex.Lock()
-	defer channel.mutex.Unlock()
-	for key, session := range channel.sessions {
-		//移除同时关闭
-		session.RemoveCloseCallback(channel, key)
-		delete(channel.sessions, key)
-		//调用回调
-		callback(session)
-	}
+	events := channel.removeAll(callback)
+	listeners := channel.listeners
+	channel.mutex.Unlock()
+
+	channel.emit(listeners, events...)
 }
 
 //关闭所有的连接
-func (channel *Channel) Close() {
+func (channel *TypedChannel[K]) Close() {
 	channel.mutex.Lock()
-	defer channel.mutex.Unlock()
+	events := channel.removeAll(nil)
+	listeners := channel.listeners
+	channel.mutex.Unlock()
+
+	channel.emit(listeners, events...)
+}
+
+//删除所有的session并返回离开的事件，callback不为nil时对每个session调用
+func (channel *TypedChannel[K]) removeAll(callback func(*Session)) []ChannelEvent[K] {
+	var events []ChannelEvent[K]
 	for key, session := range channel.sessions {
+		//移除同时关闭
 		channel.remove(key, session)
+		delete(channel.joined, key)
+		if len(channel.listeners) != 0 {
+			events = append(events, ChannelEvent[K]{Type: ChannelLeave, Key: key, Session: session})
+		}
+		//调用回调
+		if callback != nil {
+			callback(session)
+		}
+	}
+	return events
+}
+
+//把事件发送给监听者
+func (channel *TypedChannel[K]) emit(listeners []*channelListener[K], events ...ChannelEvent[K]) {
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener.callback(event)
+		}
 	}
 }
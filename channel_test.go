@@ -0,0 +1,69 @@
+package link
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_ChannelEvents(t *testing.T) {
+	channel := NewTypedChannel[string]()
+	events := make(chan ChannelEvent[string], 10)
+	unsubscribe := channel.Subscribe(func(event ChannelEvent[string]) {
+		events <- event
+	})
+
+	session1 := newSession(nil, &BlockingCodec{}, 0, BackPressureClose, 0)
+	session2 := newSession(nil, &BlockingCodec{}, 0, BackPressureClose, 0)
+
+	channel.Put("a", session1)
+	event := <-events
+	utest.EqualNow(t, event.Type, ChannelJoin)
+	utest.EqualNow(t, event.Session, session1)
+
+	joinedAt, exists := channel.JoinedAt("a")
+	utest.Assert(t, exists && !joinedAt.IsZero())
+	members := channel.Members()
+	utest.EqualNow(t, len(members), 1)
+	utest.EqualNow(t, members[0].Session, session1)
+	utest.EqualNow(t, members[0].JoinedAt, joinedAt)
+
+	channel.Put("a", session2)
+	event = <-events
+	utest.EqualNow(t, event.Type, ChannelReplace)
+	utest.EqualNow(t, event.Session, session2)
+	utest.EqualNow(t, event.Old, session1)
+
+	//被替换掉的session关闭时不影响channel，关闭回调异步执行，等回调执行完再检查
+	closed := make(chan struct{})
+	session1.AddCloseCallback(nil, nil, func(error) {
+		close(closed)
+	})
+	session1.Close()
+	<-closed
+	utest.EqualNow(t, channel.Get("a"), session2)
+	utest.EqualNow(t, channel.Len(), 1)
+	utest.EqualNow(t, len(events), 0)
+
+	reason := errors.New("kicked")
+	session2.CloseWithReason(reason)
+	event = <-events
+	utest.EqualNow(t, event.Type, ChannelLeave)
+	utest.EqualNow(t, event.Key, "a")
+	utest.EqualNow(t, event.Reason, reason)
+	utest.EqualNow(t, channel.Len(), 0)
+
+	session3 := newSession(nil, &BlockingCodec{}, 0, BackPressureClose, 0)
+	channel.Put("c", session3)
+	<-events
+	utest.Assert(t, channel.Remove("c"))
+	event = <-events
+	utest.EqualNow(t, event.Type, ChannelLeave)
+	utest.IsNilNow(t, event.Reason)
+
+	unsubscribe()
+	channel.Put("c", session3)
+	channel.Close()
+	utest.EqualNow(t, len(events), 0)
+}
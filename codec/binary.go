@@ -0,0 +1,235 @@
+package codec
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/funny/link"
+)
+
+var ErrUnknownType = errors.New("Unknown Message Type")
+var ErrBinarySize = errors.New("Binary Size Mismatch")
+
+//收到没有注册的消息时返回的错误，用数字ID区分类型时ID是收到的ID，用名字区分类型时Head是收到的名字
+type UnknownMessageError struct {
+	ID    uint32
+	Head  string
+	Named bool //用名字区分类型时为true，这时候Head可能是空字符串
+}
+
+func (e *UnknownMessageError) Error() string {
+	if e.Named {
+		return fmt.Sprintf("Unknown Message: %q", e.Head)
+	}
+	return fmt.Sprintf("Unknown Message: %d", e.ID)
+}
+
+//用数字ID区分消息类型的二进制协议，包头只有n个字节的ID
+//消息实现了encoding.BinaryMarshaler和encoding.BinaryUnmarshaler时用它们编码，否则用encoding/binary编码固定大小的结构体
+//接收时把剩下的数据全部作为包体，需要配合FixLen或者Uvarint之类的分包协议使用
+type BinaryProtocol struct {
+	n         int
+	byteOrder binary.ByteOrder
+	types     map[uint32]reflect.Type //ID-定义类型
+	ids       map[reflect.Type]uint32 //类型-ID
+}
+
+//初始化二进制协议，n是ID的字节数，只支持2和4
+func Binary(n int, byteOrder binary.ByteOrder) *BinaryProtocol {
+	if n != 2 && n != 4 {
+		panic("BinaryProtocol: unsupported id size")
+	}
+	return &BinaryProtocol{
+		n:         n,
+		byteOrder: byteOrder,
+		types:     make(map[uint32]reflect.Type),
+		ids:       make(map[reflect.Type]uint32),
+	}
+}
+
+//用ID注册一种类型，类型需要实现BinaryMarshaler和BinaryUnmarshaler或者是固定大小的
+func (p *BinaryProtocol) Register(id uint32, t interface{}) {
+	rt := reflect.TypeOf(t)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if p.n == 2 && id > math.MaxUint16 {
+		panic("BinaryProtocol: id out of range")
+	}
+	if _, exists := p.types[id]; exists {
+		panic("BinaryProtocol: duplicate id")
+	}
+	ptr := reflect.PointerTo(rt)
+	marshaler := ptr.Implements(binaryMarshalerType)
+	if marshaler != ptr.Implements(binaryUnmarshalerType) {
+		panic("BinaryProtocol: type must implement both BinaryMarshaler and BinaryUnmarshaler")
+	}
+	if !marshaler && binary.Size(reflect.New(rt).Interface()) < 0 {
+		panic("BinaryProtocol: type is neither BinaryMarshaler nor fixed size")
+	}
+	p.types[id] = rt
+	p.ids[rt] = id
+}
+
+//根据ID获取注册的类型
+func (p *BinaryProtocol) IDType(id uint32) (reflect.Type, bool) {
+	t, exists := p.types[id]
+	return t, exists
+}
+
+var binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+var binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+func (p *BinaryProtocol) NewCodec(rw io.ReadWriter) (link.Codec, error) {
+	codec := &binaryCodec{
+		p:  p,
+		rw: rw,
+	}
+	codec.closer, _ = rw.(io.Closer)
+	return codec, nil
+}
+
+type binaryCodec struct {
+	p       *BinaryProtocol
+	rw      io.ReadWriter
+	closer  io.Closer
+	recvBuf []byte
+	sendBuf []byte
+}
+
+func (c *binaryCodec) Receive() (interface{}, error) {
+	buff, err := readAll(c.rw, c.recvBuf[:0])
+	c.recvBuf = buff
+	if err != nil {
+		if len(buff) > 0 {
+			err = partialRead(err)
+		}
+		return nil, err
+	}
+	if len(buff) == 0 {
+		return nil, io.EOF
+	}
+	if len(buff) < c.p.n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var id uint32
+	if c.p.n == 2 {
+		id = uint32(c.p.byteOrder.Uint16(buff))
+	} else {
+		id = c.p.byteOrder.Uint32(buff)
+	}
+	t, exists := c.p.types[id]
+	if !exists {
+		return nil, &UnknownMessageError{ID: id}
+	}
+
+	body := reflect.New(t).Interface()
+	data := buff[c.p.n:]
+	if unmarshaler, ok := body.(encoding.BinaryUnmarshaler); ok {
+		//包体的数据会被下一次接收覆盖，复制一份
+		if err := unmarshaler.UnmarshalBinary(append([]byte(nil), data...)); err != nil {
+			return nil, err
+		}
+		return body, nil
+	}
+	if binary.Size(body) != len(data) {
+		return nil, ErrBinarySize
+	}
+	if err := binary.Read(bytes.NewReader(data), c.p.byteOrder, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (c *binaryCodec) Send(msg interface{}) error {
+	if ok, err := sendEncoded(c.p, c.rw, msg); ok {
+		return err
+	}
+	//其它协议编码的数据不能直接写入
+	if _, ok := msg.(*Encoded); ok {
+		return ErrEncodedMismatch
+	}
+
+	if msg == nil {
+		return ErrUnknownType
+	}
+
+	//统一用指针编码，指针接收者实现的BinaryMarshaler也能生效
+	v := reflect.ValueOf(msg)
+	if v.Kind() != reflect.Ptr {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr
+	}
+	id, exists := c.p.ids[v.Type().Elem()]
+	if !exists {
+		return ErrUnknownType
+	}
+
+	var head [4]byte
+	if c.p.n == 2 {
+		c.p.byteOrder.PutUint16(head[:], uint16(id))
+	} else {
+		c.p.byteOrder.PutUint32(head[:], id)
+	}
+	buff := append(c.sendBuf[:0], head[:c.p.n]...)
+	var err error
+	if marshaler, ok := v.Interface().(encoding.BinaryMarshaler); ok {
+		var data []byte
+		if data, err = marshaler.MarshalBinary(); err == nil {
+			buff = append(buff, data...)
+		}
+	} else {
+		//编码之后追加到buff后面
+		w := bytes.NewBuffer(buff)
+		err = binary.Write(w, c.p.byteOrder, v.Interface())
+		buff = w.Bytes()
+	}
+	if err != nil {
+		return err
+	}
+	c.sendBuf = buff
+	_, err = c.rw.Write(buff)
+	return err
+}
+
+//关闭二进制连接
+func (c *binaryCodec) Close() error {
+	if c.closer != nil {
+		return c.closer.Close()
+	}
+	return nil
+}
+
+//读出r中剩下的所有数据，追加到buff后面
+func readAll(r io.Reader, buff []byte) ([]byte, error) {
+	for {
+		if len(buff) == cap(buff) {
+			buff = append(buff, 0)[:len(buff)]
+		}
+		n, err := r.Read(buff[len(buff):cap(buff)])
+		buff = buff[:len(buff)+n]
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return buff, err
+		}
+	}
+}
+
+func (c *binaryCodec) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.rw, t)
+}
+
+func (c *binaryCodec) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.rw, t)
+}
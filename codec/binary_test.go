@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/funny/link"
+)
+
+type MyBinary1 struct {
+	Field1 int32
+	Field2 uint16
+	Field3 [4]byte
+}
+
+type MyBinary2 struct {
+	Field1 string
+}
+
+func (m *MyBinary2) MarshalBinary() ([]byte, error) {
+	return []byte(m.Field1), nil
+}
+
+func (m *MyBinary2) UnmarshalBinary(data []byte) error {
+	m.Field1 = string(data)
+	return nil
+}
+
+func BinaryTestProtocol() *BinaryProtocol {
+	protocol := Binary(2, binary.LittleEndian)
+	protocol.Register(1, MyBinary1{})
+	protocol.Register(2, &MyBinary2{})
+	return protocol
+}
+
+func BinaryTest(t *testing.T, protocol link.Protocol) {
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+
+	sendMsg1 := MyBinary1{-123, 456, [4]byte{1, 2, 3, 4}}
+	if err := codec.Send(sendMsg1); err != nil {
+		t.Fatal(err)
+	}
+	recvMsg1, err := codec.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recvMsg1.(*MyBinary1); !ok {
+		t.Fatalf("message type not match: %#v", recvMsg1)
+	}
+	if sendMsg1 != *(recvMsg1.(*MyBinary1)) {
+		t.Fatalf("message not match: %v, %v", sendMsg1, recvMsg1)
+	}
+
+	sendMsg2 := MyBinary2{"abc"}
+	if err := codec.Send(sendMsg2); err != nil {
+		t.Fatal(err)
+	}
+	recvMsg2, err := codec.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recvMsg2.(*MyBinary2); !ok {
+		t.Fatalf("message type not match: %#v", recvMsg2)
+	}
+	if sendMsg2 != *(recvMsg2.(*MyBinary2)) {
+		t.Fatalf("message not match: %v, %v", sendMsg2, recvMsg2)
+	}
+
+	if err := codec.Send(&MyMessage1{}); err != ErrUnknownType {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_Binary(t *testing.T) {
+	protocol := BinaryTestProtocol()
+	BinaryTest(t, FixLen(protocol, 2, binary.LittleEndian, 1024, 1024))
+	BinaryTest(t, Uvarint(protocol, 1024, 1024))
+
+	//包头只有2个字节的ID
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+	codec.Send(&MyBinary1{})
+	if stream.Len() != 2+10 {
+		t.Fatalf("unexpected size: %d", stream.Len())
+	}
+
+	//预先编码的消息
+	fixlen := FixLen(protocol, 2, binary.LittleEndian, 1024, 1024)
+	encoded, err := Encode(protocol, &MyBinary2{"abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Reset()
+	codec, _ = fixlen.NewCodec(&stream)
+	if err := codec.Send(encoded); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := codec.Receive(); err != nil || msg.(*MyBinary2).Field1 != "abc" {
+		t.Fatalf("message not match: %v, %v", msg, err)
+	}
+}
+
+func Test_BinaryUnknown(t *testing.T) {
+	protocol := BinaryTestProtocol()
+
+	var stream bytes.Buffer
+	stream.Write([]byte{3, 0})
+	codec, _ := protocol.NewCodec(&stream)
+	_, err := codec.Receive()
+	var unknown *UnknownMessageError
+	if !errors.As(err, &unknown) || unknown.ID != 3 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stream.Write([]byte{1, 0, 1, 2, 3})
+	if _, err := codec.Receive(); err != ErrBinarySize {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
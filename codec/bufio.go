@@ -3,6 +3,7 @@ package codec
 import (
 	"bufio"
 	"io"
+	"time"
 
 	"github.com/funny/link"
 )
@@ -22,7 +23,7 @@ type bufioProtocol struct {
 }
 
 func (b *bufioProtocol) NewCodec(rw io.ReadWriter) (cc link.Codec, err error) {
-	codec := new(bufioCodec)
+	codec := &bufioCodec{protocol: b}
 
 	if b.writeBuf > 0 {
 		codec.stream.w = bufio.NewWriterSize(rw, b.writeBuf)
@@ -38,6 +39,7 @@ func (b *bufioProtocol) NewCodec(rw io.ReadWriter) (cc link.Codec, err error) {
 	}
 
 	codec.stream.c, _ = rw.(io.Closer)
+	codec.stream.rw = rw
 
 	codec.base, err = b.base.NewCodec(&codec.stream)
 	if err != nil {
@@ -50,8 +52,9 @@ func (b *bufioProtocol) NewCodec(rw io.ReadWriter) (cc link.Codec, err error) {
 type bufioStream struct {
 	io.Reader
 	io.Writer
-	c io.Closer
-	w *bufio.Writer
+	c  io.Closer
+	w  *bufio.Writer
+	rw io.ReadWriter
 }
 
 func (s *bufioStream) Flush() error {
@@ -69,11 +72,19 @@ func (s *bufioStream) close() error {
 }
 
 type bufioCodec struct {
-	base   link.Codec
-	stream bufioStream
+	protocol *bufioProtocol
+	base     link.Codec
+	stream   bufioStream
 }
 
 func (c *bufioCodec) Send(msg interface{}) error {
+	if ok, err := sendEncoded(c.protocol, &c.stream, msg); ok {
+		if err != nil {
+			return err
+		}
+		return c.stream.Flush()
+	}
+
 	if err := c.base.Send(msg); err != nil {
 		return err
 	}
@@ -92,3 +103,11 @@ func (c *bufioCodec) Close() error {
 	}
 	return err2
 }
+
+func (c *bufioCodec) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.stream.rw, t)
+}
+
+func (c *bufioCodec) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.stream.rw, t)
+}
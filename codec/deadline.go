@@ -0,0 +1,36 @@
+package codec
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/funny/link"
+)
+
+var ErrNoDeadline = errors.New("Deadline Not Supported")
+var ErrPartialRead = errors.New("Partial Read")
+
+//把读超时转发给底层的连接，底层连接不支持时返回ErrNoDeadline
+func setReadDeadline(rw interface{}, t time.Time) error {
+	if d, ok := rw.(link.DeadlineCodec); ok {
+		return d.SetReadDeadline(t)
+	}
+	return ErrNoDeadline
+}
+
+//把写超时转发给底层的连接，底层连接不支持时返回ErrNoDeadline
+func setWriteDeadline(rw interface{}, t time.Time) error {
+	if d, ok := rw.(link.DeadlineCodec); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return ErrNoDeadline
+}
+
+//读取了部分数据之后超时，剩下的数据已经不能正确分包，不能再返回超时错误让调用者继续使用
+func partialRead(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrPartialRead
+	}
+	return err
+}
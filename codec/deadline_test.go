@@ -0,0 +1,135 @@
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/funny/link"
+)
+
+func receiveTimeout(session *link.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := session.ReceiveContext(ctx)
+	return err
+}
+
+func DeadlineTest(t *testing.T, protocol link.Protocol) {
+	conn1, conn2 := net.Pipe()
+	codec1, _ := protocol.NewCodec(conn1)
+	codec2, _ := protocol.NewCodec(conn2)
+	session := link.NewSession(codec1, 0)
+	defer session.Close()
+	defer codec2.Close()
+
+	//没有读取任何数据的时候被中断，session还可以继续使用
+	if err := receiveTimeout(session); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if session.IsClosed() {
+		t.Fatal("session closed")
+	}
+
+	sendMsg := MyMessage1{"abc", 123}
+	go codec2.Send(&sendMsg)
+	recvMsg, err := session.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sendMsg != *(recvMsg.(*MyMessage1)) {
+		t.Fatalf("message not match: %v, %v", sendMsg, recvMsg)
+	}
+}
+
+func Test_Deadline(t *testing.T) {
+	json := JsonTestProtocol()
+	DeadlineTest(t, json)
+	DeadlineTest(t, FixLen(json, 2, binary.LittleEndian, 1024, 1024))
+	DeadlineTest(t, Uvarint(json, 1024, 1024))
+	DeadlineTest(t, Line(json, 1024, 1024))
+	DeadlineTest(t, Bufio(FixLen(json, 2, binary.LittleEndian, 1024, 1024), 1024, 1024))
+	DeadlineTest(t, GobTestProtocol())
+}
+
+func Test_DeadlinePartial(t *testing.T) {
+	//读取了部分包头之后被中断，session会被关闭
+	conn1, conn2 := net.Pipe()
+	codec1, _ := FixLen(JsonTestProtocol(), 2, binary.LittleEndian, 1024, 1024).NewCodec(conn1)
+	session := link.NewSession(codec1, 0)
+	go conn2.Write([]byte{1})
+	if err := receiveTimeout(session); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !session.IsClosed() {
+		t.Fatal("session not closed")
+	}
+	conn2.Close()
+
+	//json.Decoder会保留已经读取的数据，被中断之后可以继续接收
+	conn1, conn2 = net.Pipe()
+	codec1, _ = JsonTestProtocol().NewCodec(conn1)
+	session = link.NewSession(codec1, 0)
+	defer session.Close()
+	defer conn2.Close()
+	go conn2.Write([]byte(`{"Head":"msg2","Bo`))
+	if err := receiveTimeout(session); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if session.IsClosed() {
+		t.Fatal("session closed")
+	}
+	go conn2.Write([]byte(`dy":{"Field1":123,"Field2":"abc"}}` + "\n"))
+	msg, err := session.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *(msg.(*MyMessage2)) != (MyMessage2{123, "abc"}) {
+		t.Fatalf("message not match: %v", msg)
+	}
+}
+
+//记录SetWriteDeadline调用次数的连接
+type deadlineConn struct {
+	net.Conn
+	writeDeadlines int32
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	atomic.AddInt32(&c.writeDeadlines, 1)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func SendDeadlineTest(t *testing.T, protocol link.Protocol) {
+	conn1, conn2 := net.Pipe()
+	defer conn2.Close()
+	conn := &deadlineConn{Conn: conn1}
+	codec, _ := protocol.NewCodec(conn)
+	session := link.NewSession(codec, 0)
+
+	//对方不读取，写超时中断发送并关闭session
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := session.SendContext(ctx, &MyMessage1{"abc", 123}); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&conn.writeDeadlines) == 0 {
+		t.Fatal("write deadline not forwarded")
+	}
+	if !session.IsClosed() || session.CloseReason() != context.DeadlineExceeded {
+		t.Fatalf("unexpected close reason: %v", session.CloseReason())
+	}
+}
+
+func Test_DeadlineSend(t *testing.T) {
+	json := JsonTestProtocol()
+	SendDeadlineTest(t, json)
+	SendDeadlineTest(t, FixLen(json, 2, binary.LittleEndian, 1024, 1024))
+	SendDeadlineTest(t, Uvarint(json, 1024, 1024))
+	SendDeadlineTest(t, Line(json, 1024, 1024))
+	SendDeadlineTest(t, Bufio(FixLen(json, 2, binary.LittleEndian, 1024, 1024), 1024, 1024))
+	SendDeadlineTest(t, GobTestProtocol())
+}
@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/funny/link"
+)
+
+var ErrDelimiterInBody = errors.New("Delimiter In Body")
+
+//用分隔符分包的协议，用于文本协议
+//maxRecv和maxSend是不包括分隔符的包体长度
+type DelimitProtocol struct {
+	base    link.Protocol
+	delim   []byte
+	maxRecv int
+	maxSend int
+	trimCR  bool
+}
+
+func Delimit(base link.Protocol, delim []byte, maxRecv, maxSend int) *DelimitProtocol {
+	if len(delim) == 0 {
+		panic("DelimitProtocol: empty delimiter")
+	}
+	return &DelimitProtocol{
+		base:    base,
+		delim:   append([]byte(nil), delim...),
+		maxRecv: maxRecv,
+		maxSend: maxSend,
+	}
+}
+
+//按行分包，发送时用\n结尾，接收时同时兼容\r\n结尾
+func Line(base link.Protocol, maxRecv, maxSend int) *DelimitProtocol {
+	proto := Delimit(base, []byte{'\n'}, maxRecv, maxSend)
+	proto.trimCR = true
+	return proto
+}
+
+//设置接收时是否去掉包体末尾的\r，用于把\r\n结尾统一处理
+func (p *DelimitProtocol) TrimCR(trim bool) *DelimitProtocol {
+	p.trimCR = trim
+	return p
+}
+
+func (p *DelimitProtocol) NewCodec(rw io.ReadWriter) (cc link.Codec, err error) {
+	codec := &delimitCodec{
+		rw:              rw,
+		reader:          bufio.NewReader(rw),
+		DelimitProtocol: p,
+	}
+
+	codec.base, err = p.base.NewCodec(&codec.fixlenReadWriter)
+	if err != nil {
+		return
+	}
+	cc = codec
+	return
+}
+
+type delimitCodec struct {
+	base    link.Codec
+	bodyBuf []byte
+	rw      io.ReadWriter
+	reader  *bufio.Reader
+	*DelimitProtocol
+	fixlenReadWriter
+}
+
+//读取一个包，返回的包体不包括分隔符，trimCR时也不包括末尾的\r
+func (c *delimitCodec) readBody() ([]byte, error) {
+	last := c.delim[len(c.delim)-1]
+	limit := c.maxRecv + len(c.delim)
+	if c.trimCR {
+		limit++ //末尾的\r不算在包体长度里
+	}
+	c.bodyBuf = c.bodyBuf[:0]
+	for {
+		line, err := c.reader.ReadSlice(last)
+		if len(c.bodyBuf)+len(line) > limit {
+			return nil, ErrTooLargePacket
+		}
+		c.bodyBuf = append(c.bodyBuf, line...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if len(c.bodyBuf) > 0 {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				err = partialRead(err)
+			}
+			return nil, err
+		}
+		//多字节的分隔符需要检查前面的字节是否匹配
+		if bytes.HasSuffix(c.bodyBuf, c.delim) {
+			body := c.bodyBuf[:len(c.bodyBuf)-len(c.delim)]
+			if c.trimCR {
+				body = bytes.TrimSuffix(body, []byte{'\r'})
+			}
+			if len(body) > c.maxRecv {
+				return nil, ErrTooLargePacket
+			}
+			return body, nil
+		}
+	}
+}
+
+func (c *delimitCodec) Receive() (interface{}, error) {
+	body, err := c.readBody()
+	if err != nil {
+		return nil, err
+	}
+	c.recvBuf.Reset(body)
+	msg, err := c.base.Receive()
+	return msg, err
+}
+
+func (c *delimitCodec) Send(msg interface{}) error {
+	if ok, err := sendEncoded(c.DelimitProtocol, c.rw, msg); ok {
+		return err
+	}
+
+	c.sendBuf.Reset()
+	err := c.base.Send(msg)
+	if err != nil {
+		return err
+	}
+	//json.Encoder之类的会在末尾加上换行，去掉一个末尾的分隔符
+	body := bytes.TrimSuffix(c.sendBuf.Bytes(), c.delim)
+	if len(body) > c.maxSend {
+		return ErrTooLargePacket
+	}
+	if bytes.Contains(body, c.delim) {
+		return ErrDelimiterInBody
+	}
+	c.sendBuf.Truncate(len(body))
+	c.sendBuf.Write(c.delim)
+	_, err = c.rw.Write(c.sendBuf.Bytes())
+	return err
+}
+
+func (c *delimitCodec) Close() error {
+	if closer, ok := c.rw.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *delimitCodec) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.rw, t)
+}
+
+func (c *delimitCodec) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.rw, t)
+}
@@ -0,0 +1,65 @@
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func Test_Delimit(t *testing.T) {
+	base := JsonTestProtocol()
+	JsonTest(t, Line(base, 1024, 1024))
+	JsonTest(t, Delimit(base, []byte("\r\n\r\n"), 1024, 1024))
+	EncodedTest(t, Line(base, 1024, 1024), base)
+}
+
+func Test_Line(t *testing.T) {
+	protocol := Line(String(), 8, 8)
+
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+	for _, msg := range []interface{}{"abc", []byte("def"), ""} {
+		if err := codec.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stream.String() != "abc\ndef\n\n" {
+		t.Fatalf("stream not match: %q", stream.String())
+	}
+
+	stream.WriteString("ghi\r\n12345678\n12345678\r\n123456789\n")
+	for _, expect := range []string{"abc", "def", "", "ghi", "12345678", "12345678"} {
+		msg, err := codec.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.(string) != expect {
+			t.Fatalf("message not match: %q, %q", msg, expect)
+		}
+	}
+	if _, err := codec.Receive(); err != ErrTooLargePacket {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	//\r不算在包体长度里，但是去掉\r之后超过长度的包还是会被拒绝
+	stream.Reset()
+	stream.WriteString("123456789\r\n")
+	if _, err := codec.Receive(); err != ErrTooLargePacket {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := codec.Send("a\nb"); err != ErrDelimiterInBody {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := codec.Send("123456789"); err != ErrTooLargePacket {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := codec.Send(123); err != ErrNotString {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	codec, _ = protocol.NewCodec(&readWriter{bytes.NewReader([]byte("abc")), io.Discard})
+	if _, err := codec.Receive(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
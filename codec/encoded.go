@@ -0,0 +1,47 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/funny/link"
+)
+
+var ErrEncodedMismatch = errors.New("Encoded Protocol Mismatch")
+
+//预先编码好的消息，广播时只需要编码一次
+//codec包中的Codec收到由同一个协议编码的Encoded时直接写入编码好的数据
+//外层协议收到内层协议编码的Encoded时只做外层的处理，例如FixLen只加上包头
+type Encoded struct {
+	protocol link.Protocol
+	data     []byte
+}
+
+//用protocol把msg编码成Encoded，protocol的Codec不能依赖连接相关的状态
+func Encode(protocol link.Protocol, msg interface{}) (*Encoded, error) {
+	var buf bytes.Buffer
+	codec, err := protocol.NewCodec(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if err := codec.Send(msg); err != nil {
+		return nil, err
+	}
+	return &Encoded{protocol, buf.Bytes()}, nil
+}
+
+//编码好的数据
+func (e *Encoded) Bytes() []byte {
+	return e.data
+}
+
+//msg是protocol编码的Encoded时把数据写入w，返回msg是否是Encoded
+func sendEncoded(protocol link.Protocol, w io.Writer, msg interface{}) (bool, error) {
+	e, ok := msg.(*Encoded)
+	if !ok || e.protocol != protocol {
+		return false, nil
+	}
+	_, err := w.Write(e.data)
+	return true, err
+}
@@ -0,0 +1,55 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/funny/link"
+)
+
+func EncodedTest(t *testing.T, protocol, encoder link.Protocol) {
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+
+	sendMsg := MyMessage1{
+		Field1: "abc",
+		Field2: 123,
+	}
+	encoded, err := Encode(encoder, &sendMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := codec.Send(encoded); err != nil {
+			t.Fatal(err)
+		}
+		recvMsg, err := codec.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sendMsg != *(recvMsg.(*MyMessage1)) {
+			t.Fatalf("message not match: %v, %v", sendMsg, recvMsg)
+		}
+	}
+}
+
+func Test_Encoded(t *testing.T) {
+	json := JsonTestProtocol()
+	fixlen := FixLen(json, 2, binary.LittleEndian, 1024, 1024)
+	bufio := Bufio(fixlen, 1024, 1024)
+
+	EncodedTest(t, json, json)
+	EncodedTest(t, fixlen, fixlen)
+	EncodedTest(t, fixlen, json)
+	EncodedTest(t, bufio, bufio)
+	EncodedTest(t, bufio, fixlen)
+
+	var stream bytes.Buffer
+	codec, _ := json.NewCodec(&stream)
+	encoded, _ := Encode(JsonTestProtocol(), &MyMessage1{})
+	if err := codec.Send(encoded); err != ErrEncodedMismatch {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
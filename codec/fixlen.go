@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"math"
+	"time"
 
 	"github.com/funny/link"
 )
@@ -120,7 +121,10 @@ type fixlenCodec struct {
 }
 
 func (c *fixlenCodec) Receive() (interface{}, error) {
-	if _, err := io.ReadFull(c.rw, c.headBuf); err != nil {
+	if n, err := io.ReadFull(c.rw, c.headBuf); err != nil {
+		if n > 0 {
+			err = partialRead(err)
+		}
 		return nil, err
 	}
 	size := c.headDecoder(c.headBuf)
@@ -132,7 +136,7 @@ func (c *fixlenCodec) Receive() (interface{}, error) {
 	}
 	buff := c.bodyBuf[:size]
 	if _, err := io.ReadFull(c.rw, buff); err != nil {
-		return nil, err
+		return nil, partialRead(err)
 	}
 	c.recvBuf.Reset(buff)
 	msg, err := c.base.Receive()
@@ -140,6 +144,10 @@ func (c *fixlenCodec) Receive() (interface{}, error) {
 }
 
 func (c *fixlenCodec) Send(msg interface{}) error {
+	if ok, err := sendEncoded(c.FixLenProtocol, c.rw, msg); ok {
+		return err
+	}
+
 	c.sendBuf.Reset()
 	c.sendBuf.Write(c.headBuf)
 	err := c.base.Send(msg)
@@ -158,3 +166,11 @@ func (c *fixlenCodec) Close() error {
 	}
 	return nil
 }
+
+func (c *fixlenCodec) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.rw, t)
+}
+
+func (c *fixlenCodec) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.rw, t)
+}
@@ -0,0 +1,159 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/funny/link"
+)
+
+//gob协议，每个连接共用一个Encoder和Decoder，同一种类型的定义只发送一次
+//因为编码结果依赖连接的状态，所以不支持Encode预先编码的消息
+type GobProtocol struct {
+	types map[string]reflect.Type //string-定义类型
+	names map[reflect.Type]string //类型-string
+}
+
+//初始化gob协议
+func Gob() *GobProtocol {
+	return &GobProtocol{
+		types: make(map[string]reflect.Type),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+//注册任意类型
+func (g *GobProtocol) Register(t interface{}) {
+	rt := reflect.TypeOf(t)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	name := rt.PkgPath() + "/" + rt.Name()
+	g.types[name] = rt
+	g.names[rt] = name
+}
+
+//注册一种类型，根据名字
+func (g *GobProtocol) RegisterName(name string, t interface{}) {
+	rt := reflect.TypeOf(t)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	g.types[name] = rt
+	g.names[rt] = name
+}
+
+//根据注册的名字获取类型
+func (g *GobProtocol) NameType(name string) (reflect.Type, bool) {
+	t, exists := g.types[name]
+	return t, exists
+}
+
+func (g *GobProtocol) NewCodec(rw io.ReadWriter) (link.Codec, error) {
+	codec := &gobCodec{
+		p:       g,
+		rw:      rw,
+		reader:  gobReader{r: bufio.NewReader(rw)},
+		encoder: gob.NewEncoder(rw),
+	}
+	codec.decoder = gob.NewDecoder(&codec.reader)
+	codec.closer, _ = rw.(io.Closer)
+	return codec, nil
+}
+
+//记录读取了多少数据，用来判断超时的时候有没有读取部分数据
+//实现了io.ByteReader，gob.Decoder不会再包一层bufio
+type gobReader struct {
+	r *bufio.Reader
+	n int
+}
+
+func (r *gobReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func (r *gobReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return b, err
+}
+
+//没有注册的类型放在interface里面发送，需要用gob.Register注册
+type gobAny struct {
+	Body interface{}
+}
+
+type gobCodec struct {
+	p       *GobProtocol
+	rw      io.ReadWriter
+	closer  io.Closer
+	reader  gobReader
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+}
+
+func (c *gobCodec) Receive() (interface{}, error) {
+	var head string
+	c.reader.n = 0
+	if err := c.decoder.Decode(&head); err != nil {
+		if c.reader.n > 0 {
+			err = partialRead(err)
+		}
+		return nil, err
+	}
+	if t, exists := c.p.types[head]; exists {
+		body := reflect.New(t).Interface()
+		if err := c.decoder.Decode(body); err != nil {
+			return nil, partialRead(err)
+		}
+		return body, nil
+	}
+	var holder gobAny
+	if err := c.decoder.Decode(&holder); err != nil {
+		return nil, partialRead(err)
+	}
+	return holder.Body, nil
+}
+
+func (c *gobCodec) Send(msg interface{}) error {
+	//编码结果依赖连接的状态，不能直接写入预先编码的数据
+	if _, ok := msg.(*Encoded); ok {
+		return ErrEncodedMismatch
+	}
+
+	t := reflect.TypeOf(msg)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name, exists := c.p.names[t]
+	if err := c.encoder.Encode(name); err != nil {
+		return err
+	}
+	if exists {
+		return c.encoder.Encode(msg)
+	}
+	return c.encoder.Encode(&gobAny{msg})
+}
+
+//关闭gob连接
+func (c *gobCodec) Close() error {
+	if c.closer != nil {
+		return c.closer.Close()
+	}
+	return nil
+}
+
+func (c *gobCodec) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.rw, t)
+}
+
+func (c *gobCodec) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.rw, t)
+}
@@ -0,0 +1,103 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"reflect"
+	"testing"
+
+	"github.com/funny/link"
+)
+
+type MyMessage3 struct {
+	Field1 []string
+}
+
+func init() {
+	gob.Register(MyMessage3{})
+}
+
+func GobTestProtocol() *GobProtocol {
+	protocol := Gob()
+	protocol.Register(MyMessage1{})
+	protocol.RegisterName("msg2", &MyMessage2{})
+	return protocol
+}
+
+func GobTest(t *testing.T, protocol link.Protocol) {
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+
+	for i := 0; i < 2; i++ {
+		sendMsg1 := MyMessage1{"abc", 123 + i}
+		if err := codec.Send(&sendMsg1); err != nil {
+			t.Fatal(err)
+		}
+		recvMsg1, err := codec.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := recvMsg1.(*MyMessage1); !ok {
+			t.Fatalf("message type not match: %#v", recvMsg1)
+		}
+		if sendMsg1 != *(recvMsg1.(*MyMessage1)) {
+			t.Fatalf("message not match: %v, %v", sendMsg1, recvMsg1)
+		}
+
+		sendMsg2 := MyMessage2{123 + i, "abc"}
+		if err := codec.Send(sendMsg2); err != nil {
+			t.Fatal(err)
+		}
+		recvMsg2, err := codec.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := recvMsg2.(*MyMessage2); !ok {
+			t.Fatalf("message type not match: %#v", recvMsg2)
+		}
+		if sendMsg2 != *(recvMsg2.(*MyMessage2)) {
+			t.Fatalf("message not match: %v, %v", sendMsg2, recvMsg2)
+		}
+
+		//没有在协议中注册的类型
+		sendMsg3 := MyMessage3{[]string{"abc", "def"}}
+		if err := codec.Send(&sendMsg3); err != nil {
+			t.Fatal(err)
+		}
+		recvMsg3, err := codec.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(recvMsg3, sendMsg3) {
+			t.Fatalf("message not match: %#v, %#v", sendMsg3, recvMsg3)
+		}
+	}
+}
+
+func Test_Gob(t *testing.T) {
+	protocol := GobTestProtocol()
+	GobTest(t, protocol)
+	GobTest(t, FixLen(protocol, 2, binary.LittleEndian, 1024, 1024))
+	GobTest(t, Bufio(protocol, 1024, 1024))
+	GobTest(t, Bufio(FixLen(protocol, 2, binary.LittleEndian, 1024, 1024), 1024, 1024))
+}
+
+func Test_GobTypeOnce(t *testing.T) {
+	var stream bytes.Buffer
+	codec, _ := GobTestProtocol().NewCodec(&stream)
+
+	//第二次发送同一种类型时不再发送类型定义
+	codec.Send(&MyMessage1{"abc", 123})
+	n := stream.Len()
+	stream.Reset()
+	codec.Send(&MyMessage1{"abc", 123})
+	if stream.Len() >= n {
+		t.Fatalf("type sent twice: %d, %d", n, stream.Len())
+	}
+
+	encoded, _ := Encode(JsonTestProtocol(), &MyMessage1{})
+	if err := codec.Send(encoded); err != ErrEncodedMismatch {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
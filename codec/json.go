@@ -1,23 +1,38 @@
 package codec
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"os"
 	"reflect"
+	"strconv"
+	"time"
 
 	"github.com/funny/link"
 )
 
+var ErrMissingBody = errors.New("Missing Body")
+
 type JsonProtocol struct {
-	types map[string]reflect.Type //string-定义类型
-	names map[reflect.Type]string //类型-string
+	types   map[string]reflect.Type //string-定义类型
+	names   map[reflect.Type]string //类型-string
+	idTypes map[uint32]reflect.Type //数字ID-定义类型
+	ids     map[reflect.Type]uint32 //类型-数字ID
+
+	strict                bool //收到没有注册的Head时返回UnknownMessageError
+	disallowUnknownFields bool //包体中有类型没有的字段时返回错误
+	useNumber             bool //把包体中的数字解析成json.Number
 }
 
 //初始化json协议
 func Json() *JsonProtocol {
 	return &JsonProtocol{
-		types: make(map[string]reflect.Type),
-		names: make(map[reflect.Type]string),
+		types:   make(map[string]reflect.Type),
+		names:   make(map[reflect.Type]string),
+		idTypes: make(map[uint32]reflect.Type),
+		ids:     make(map[reflect.Type]uint32),
 	}
 }
 
@@ -42,9 +57,76 @@ func (j *JsonProtocol) RegisterName(name string, t interface{}) {
 	j.names[rt] = name
 }
 
+//用数字ID注册一种类型，发送时Head是数字，比包名加类型名短，同时用名字注册时优先使用数字ID
+//和BinaryProtocol一样，重复注册同一个ID会panic
+func (j *JsonProtocol) RegisterID(id uint32, t interface{}) {
+	rt := reflect.TypeOf(t)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if _, exists := j.idTypes[id]; exists {
+		panic("JsonProtocol: duplicate id")
+	}
+	j.idTypes[id] = rt
+	j.ids[rt] = id
+}
+
+//根据注册的名字获取类型
+func (j *JsonProtocol) NameType(name string) (reflect.Type, bool) {
+	t, exists := j.types[name]
+	return t, exists
+}
+
+//根据注册的数字ID获取类型
+func (j *JsonProtocol) IDType(id uint32) (reflect.Type, bool) {
+	t, exists := j.idTypes[id]
+	return t, exists
+}
+
+//严格模式，收到没有注册的Head时返回UnknownMessageError，而不是解析成map[string]interface{}
+func (j *JsonProtocol) Strict(strict bool) *JsonProtocol {
+	j.strict = strict
+	return j
+}
+
+//包体中有类型没有的字段时返回错误
+func (j *JsonProtocol) DisallowUnknownFields(disallow bool) *JsonProtocol {
+	j.disallowUnknownFields = disallow
+	return j
+}
+
+//把包体中的数字解析成json.Number，避免int64被转成float64丢失精度
+func (j *JsonProtocol) UseNumber(useNumber bool) *JsonProtocol {
+	j.useNumber = useNumber
+	return j
+}
+
+//根据Head获取注册的类型，Head可以是名字或者数字ID
+func (j *JsonProtocol) headType(head json.RawMessage) (reflect.Type, error) {
+	if len(head) > 0 && head[0] == '"' {
+		var name string
+		if err := json.Unmarshal(head, &name); err != nil {
+			return nil, err
+		}
+		if t, exists := j.types[name]; exists {
+			return t, nil
+		}
+		return nil, &UnknownMessageError{Head: name, Named: true}
+	}
+	if id, err := strconv.ParseUint(string(head), 10, 32); err == nil {
+		if t, exists := j.idTypes[uint32(id)]; exists {
+			return t, nil
+		}
+		return nil, &UnknownMessageError{ID: uint32(id)}
+	}
+	//没有Head或者Head既不是名字也不是数字ID
+	return nil, &UnknownMessageError{Head: string(head), Named: true}
+}
+
 func (j *JsonProtocol) NewCodec(rw io.ReadWriter) (link.Codec, error) {
 	codec := &jsonCodec{
 		p:       j,
+		rw:      rw,
 		encoder: json.NewEncoder(rw),
 		decoder: json.NewDecoder(rw),
 	}
@@ -53,17 +135,18 @@ func (j *JsonProtocol) NewCodec(rw io.ReadWriter) (link.Codec, error) {
 }
 
 type jsonIn struct {
-	Head string
-	Body *json.RawMessage
+	Head json.RawMessage
+	Body json.RawMessage
 }
 
 type jsonOut struct {
-	Head string
+	Head interface{} //名字或者数字ID
 	Body interface{}
 }
 
 type jsonCodec struct {
 	p       *JsonProtocol
+	rw      io.ReadWriter
 	closer  io.Closer
 	encoder *json.Encoder
 	decoder *json.Decoder
@@ -73,29 +156,64 @@ func (c *jsonCodec) Receive() (interface{}, error) {
 	var in jsonIn
 	err := c.decoder.Decode(&in)
 	if err != nil {
+		//json.Decoder出错之后不能继续使用，超时的时候用还没有解析的数据重新创建一个，不会丢失数据
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			buffered, _ := io.ReadAll(c.decoder.Buffered())
+			c.decoder = json.NewDecoder(io.MultiReader(bytes.NewReader(buffered), c.rw))
+		}
 		return nil, err
 	}
 	var body interface{}
-	if in.Head != "" {
-		if t, exists := c.p.types[in.Head]; exists {
-			body = reflect.New(t).Interface()
+	if t, err := c.p.headType(in.Head); err == nil {
+		body = reflect.New(t).Interface()
+	} else if c.p.strict {
+		return nil, err
+	}
+
+	//消息格式正确但是没有包体，和数据流被截断区分开
+	if len(in.Body) == 0 {
+		return nil, ErrMissingBody
+	}
+	//没有设置解析选项的时候不需要额外创建json.Decoder
+	if !c.p.disallowUnknownFields && !c.p.useNumber {
+		if err = json.Unmarshal(in.Body, &body); err != nil {
+			return nil, err
 		}
+		return body, nil
 	}
-	err = json.Unmarshal(*in.Body, &body)
-	if err != nil {
+	decoder := json.NewDecoder(bytes.NewReader(in.Body))
+	if c.p.disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if c.p.useNumber {
+		decoder.UseNumber()
+	}
+	if err = decoder.Decode(&body); err != nil {
 		return nil, err
 	}
 	return body, nil
 }
 
 func (c *jsonCodec) Send(msg interface{}) error {
+	if ok, err := sendEncoded(c.p, c.rw, msg); ok {
+		return err
+	}
+	//其它协议编码的数据不能直接写入
+	if _, ok := msg.(*Encoded); ok {
+		return ErrEncodedMismatch
+	}
+
 	var out jsonOut
 	t := reflect.TypeOf(msg)
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
-	if name, exists := c.p.names[t]; exists {
+	if id, exists := c.p.ids[t]; exists {
+		out.Head = id
+	} else if name, exists := c.p.names[t]; exists {
 		out.Head = name
+	} else {
+		out.Head = ""
 	}
 	out.Body = msg
 	return c.encoder.Encode(&out)
@@ -108,3 +226,11 @@ func (c *jsonCodec) Close() error {
 	}
 	return nil
 }
+
+func (c *jsonCodec) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.rw, t)
+}
+
+func (c *jsonCodec) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.rw, t)
+}
@@ -2,6 +2,10 @@ package codec
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/funny/link"
@@ -109,3 +113,132 @@ func Test_Json(t *testing.T) {
 	protocol := JsonTestProtocol()
 	JsonTest(t, protocol)
 }
+
+func Test_JsonNameType(t *testing.T) {
+	protocol := JsonTestProtocol()
+	if rt, exists := protocol.NameType("msg2"); !exists || rt != reflect.TypeOf(MyMessage2{}) {
+		t.Fatalf("name type not match: %v", rt)
+	}
+	if _, exists := protocol.NameType("msg3"); exists {
+		t.Fatal("unexpected name type")
+	}
+}
+
+func Test_JsonID(t *testing.T) {
+	protocol := JsonTestProtocol()
+	protocol.RegisterID(1, MyMessage1{})
+	JsonTest(t, protocol)
+
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+	codec.Send(&MyMessage1{"abc", 123})
+	if !strings.HasPrefix(stream.String(), `{"Head":1,`) {
+		t.Fatalf("head not match: %s", stream.String())
+	}
+	if rt, exists := protocol.IDType(1); !exists || rt != reflect.TypeOf(MyMessage1{}) {
+		t.Fatalf("id type not match: %v", rt)
+	}
+}
+
+func Test_JsonDuplicateID(t *testing.T) {
+	protocol := JsonTestProtocol()
+	protocol.RegisterID(1, MyMessage1{})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate id accepted")
+		}
+	}()
+	protocol.RegisterID(1, MyMessage2{})
+}
+
+func Test_JsonStrict(t *testing.T) {
+	protocol := JsonTestProtocol().Strict(true)
+
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+	stream.WriteString(`{"Head":"msg3","Body":{}}` + "\n")
+	stream.WriteString(`{"Head":3,"Body":{}}` + "\n")
+	stream.WriteString(`{"Head":"","Body":{}}` + "\n")
+	stream.WriteString(`{"Body":{}}` + "\n")
+	stream.WriteString(`{"Head":"msg2"}` + "\n")
+
+	_, err := codec.Receive()
+	var unknown *UnknownMessageError
+	if !errors.As(err, &unknown) || !unknown.Named || unknown.Head != "msg3" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = codec.Receive()
+	if !errors.As(err, &unknown) || unknown.Named || unknown.ID != 3 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err.Error() != "Unknown Message: 3" {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+	//空名字和没有Head都不能被当成ID为0的消息
+	for i := 0; i < 2; i++ {
+		_, err = codec.Receive()
+		if !errors.As(err, &unknown) || !unknown.Named || unknown.Head != "" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err.Error() != `Unknown Message: ""` {
+			t.Fatalf("unexpected error message: %v", err)
+		}
+	}
+	if _, err = codec.Receive(); err != ErrMissingBody {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	//没有注册的类型
+	codec.Send(map[string]int{"a": 1})
+	if _, err := codec.Receive(); !errors.As(err, &unknown) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_JsonOptions(t *testing.T) {
+	protocol := JsonTestProtocol().DisallowUnknownFields(true).UseNumber(true)
+
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+	stream.WriteString(`{"Head":"msg2","Body":{"Field1":1,"Field3":2}}` + "\n")
+	if _, err := codec.Receive(); err == nil {
+		t.Fatal("unknown field accepted")
+	}
+
+	codec.Send(map[string]int64{"id": 1<<62 + 1})
+	msg, err := codec.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := msg.(map[string]interface{})["id"].(json.Number).Int64(); id != 1<<62+1 {
+		t.Fatalf("number not match: %v", msg)
+	}
+}
+
+func Test_JsonMissingBody(t *testing.T) {
+	protocol := JsonTestProtocol()
+
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+	stream.WriteString(`{"Head":"msg2"}` + "\n")
+	stream.WriteString(`{"Head":"msg3"}` + "\n")
+	stream.WriteString(`{}` + "\n")
+
+	//没有包体的时候返回ErrMissingBody，不能当成数据流被截断
+	for i := 0; i < 3; i++ {
+		if _, err := codec.Receive(); err != ErrMissingBody {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	//出错之后还可以继续接收
+	sendMsg := MyMessage2{123, "abc"}
+	codec.Send(&sendMsg)
+	msg, err := codec.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sendMsg != *(msg.(*MyMessage2)) {
+		t.Fatalf("message not match: %v, %v", sendMsg, msg)
+	}
+}
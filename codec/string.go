@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/funny/link"
+)
+
+var ErrNotString = errors.New("Not String")
+
+//字符串协议，接收时把数据全部读出来作为一个string，需要配合FixLen或者Line之类的分包协议使用
+//发送时只接受string和[]byte
+func String() link.Protocol {
+	return stringProtocol{}
+}
+
+type stringProtocol struct{}
+
+func (p stringProtocol) NewCodec(rw io.ReadWriter) (link.Codec, error) {
+	codec := &stringCodec{
+		p:  p,
+		rw: rw,
+	}
+	codec.closer, _ = rw.(io.Closer)
+	return codec, nil
+}
+
+type stringCodec struct {
+	p      stringProtocol
+	rw     io.ReadWriter
+	closer io.Closer
+}
+
+func (c *stringCodec) Receive() (interface{}, error) {
+	data, err := readAll(c.rw, nil)
+	if err != nil {
+		if len(data) > 0 {
+			err = partialRead(err)
+		}
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *stringCodec) Send(msg interface{}) error {
+	if ok, err := sendEncoded(c.p, c.rw, msg); ok {
+		return err
+	}
+
+	var err error
+	switch v := msg.(type) {
+	case string:
+		_, err = io.WriteString(c.rw, v)
+	case []byte:
+		_, err = c.rw.Write(v)
+	default:
+		err = ErrNotString
+	}
+	return err
+}
+
+func (c *stringCodec) Close() error {
+	if c.closer != nil {
+		return c.closer.Close()
+	}
+	return nil
+}
+
+func (c *stringCodec) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.rw, t)
+}
+
+func (c *stringCodec) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.rw, t)
+}
@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/funny/link"
+)
+
+var ErrBadVarint = errors.New("Bad Varint")
+
+//用uvarint做包头的分包协议，包头的长度随包体的大小变化，和protobuf的流格式一样
+//rw没有实现io.ByteReader的时候包头逐个字节读取，建议在外面包一层Bufio
+type UvarintProtocol struct {
+	base    link.Protocol
+	maxRecv int
+	maxSend int
+}
+
+func Uvarint(base link.Protocol, maxRecv, maxSend int) *UvarintProtocol {
+	return &UvarintProtocol{
+		base:    base,
+		maxRecv: maxRecv,
+		maxSend: maxSend,
+	}
+}
+
+func (p *UvarintProtocol) NewCodec(rw io.ReadWriter) (cc link.Codec, err error) {
+	codec := &uvarintCodec{
+		rw:              rw,
+		UvarintProtocol: p,
+	}
+	codec.byteReader, _ = rw.(io.ByteReader)
+
+	codec.base, err = p.base.NewCodec(&codec.fixlenReadWriter)
+	if err != nil {
+		return
+	}
+	cc = codec
+	return
+}
+
+type uvarintCodec struct {
+	base       link.Codec
+	head       [binary.MaxVarintLen64]byte
+	bodyBuf    []byte
+	rw         io.ReadWriter
+	byteReader io.ByteReader
+	*UvarintProtocol
+	fixlenReadWriter
+}
+
+//读取一个字节
+func (c *uvarintCodec) readByte() (byte, error) {
+	if c.byteReader != nil {
+		return c.byteReader.ReadByte()
+	}
+	if _, err := io.ReadFull(c.rw, c.head[:1]); err != nil {
+		return 0, err
+	}
+	return c.head[0], nil
+}
+
+//读取包头，拒绝超长的或者不是最短编码的uvarint
+func (c *uvarintCodec) readHead() (int, error) {
+	var size uint64
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := c.readByte()
+		if err != nil {
+			if i > 0 {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				err = partialRead(err)
+			}
+			return 0, err
+		}
+		if b < 0x80 {
+			if i > 0 && b == 0 {
+				return 0, ErrBadVarint
+			}
+			if i == binary.MaxVarintLen64-1 && b > 1 {
+				return 0, ErrBadVarint
+			}
+			size |= uint64(b) << (7 * uint(i))
+			if size > uint64(c.maxRecv) {
+				return 0, ErrTooLargePacket
+			}
+			return int(size), nil
+		}
+		size |= uint64(b&0x7f) << (7 * uint(i))
+	}
+	return 0, ErrBadVarint
+}
+
+func (c *uvarintCodec) Receive() (interface{}, error) {
+	size, err := c.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if cap(c.bodyBuf) < size {
+		c.bodyBuf = make([]byte, size, size+128)
+	}
+	buff := c.bodyBuf[:size]
+	if _, err := io.ReadFull(c.rw, buff); err != nil {
+		return nil, partialRead(err)
+	}
+	c.recvBuf.Reset(buff)
+	msg, err := c.base.Receive()
+	return msg, err
+}
+
+func (c *uvarintCodec) Send(msg interface{}) error {
+	if ok, err := sendEncoded(c.UvarintProtocol, c.rw, msg); ok {
+		return err
+	}
+
+	//预留最长的包头，编码完之后把包头靠右写在包体前面
+	c.sendBuf.Reset()
+	c.sendBuf.Write(c.head[:])
+	err := c.base.Send(msg)
+	if err != nil {
+		return err
+	}
+	buff := c.sendBuf.Bytes()
+	size := len(buff) - binary.MaxVarintLen64
+	if size > c.maxSend {
+		return ErrTooLargePacket
+	}
+	var head [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(head[:], uint64(size))
+	buff = buff[binary.MaxVarintLen64-n:]
+	copy(buff, head[:n])
+	_, err = c.rw.Write(buff)
+	return err
+}
+
+func (c *uvarintCodec) Close() error {
+	if closer, ok := c.rw.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *uvarintCodec) SetReadDeadline(t time.Time) error {
+	return setReadDeadline(c.rw, t)
+}
+
+func (c *uvarintCodec) SetWriteDeadline(t time.Time) error {
+	return setWriteDeadline(c.rw, t)
+}
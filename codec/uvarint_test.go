@@ -0,0 +1,72 @@
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+func Test_Uvarint(t *testing.T) {
+	base := JsonTestProtocol()
+	protocol := Uvarint(base, 1024, 1024)
+	JsonTest(t, protocol)
+	EncodedTest(t, protocol, protocol)
+	EncodedTest(t, protocol, base)
+	JsonTest(t, Bufio(protocol, 1024, 1024))
+}
+
+func Test_UvarintError(t *testing.T) {
+	protocol := Uvarint(JsonTestProtocol(), 16, 16)
+
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+	if err := codec.Send(&MyMessage1{"abcdefghijklmn", 123}); err != ErrTooLargePacket {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, head := range [][]byte{
+		{0x80, 0x00}, //不是最短编码
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, //超过uint64
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+	} {
+		codec, _ := protocol.NewCodec(&readWriter{bytes.NewReader(head), io.Discard})
+		if _, err := codec.Receive(); err != ErrBadVarint {
+			t.Fatalf("unexpected error: %v, %x", err, head)
+		}
+	}
+
+	codec, _ = protocol.NewCodec(&readWriter{bytes.NewReader([]byte{0x11}), io.Discard})
+	if _, err := codec.Receive(); err != ErrTooLargePacket {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	codec, _ = protocol.NewCodec(&readWriter{bytes.NewReader([]byte{0x81}), io.Discard})
+	if _, err := codec.Receive(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func FuzzUvarint(f *testing.F) {
+	protocol := Uvarint(JsonTestProtocol(), 1024, 1024)
+
+	var stream bytes.Buffer
+	codec, _ := protocol.NewCodec(&stream)
+	codec.Send(&MyMessage1{"abc", 123})
+	f.Add(stream.Bytes())
+	f.Add([]byte{0x80, 0x00})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		codec, _ := protocol.NewCodec(&readWriter{bytes.NewReader(data), io.Discard})
+		for i := 0; i < 10; i++ {
+			if _, err := codec.Receive(); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package link
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+//按照主题管理多个Channel的发布订阅中心
+//每个主题对应一个以session id为key的Channel，Channel的State是主题名
+//session关闭时自动异步取消它的所有订阅，没有订阅者的主题会被删除
+type Hub struct {
+	mutex  sync.Mutex
+	topics map[string]*TypedChannel[uint64] //主题对应的Channel
+	subs   map[uint64]map[string]struct{}   //session订阅的主题
+}
+
+//新建一个Hub
+func NewHub() *Hub {
+	return &Hub{
+		topics: make(map[string]*TypedChannel[uint64]),
+		subs:   make(map[uint64]map[string]struct{}),
+	}
+}
+
+//订阅主题
+func (hub *Hub) Subscribe(topic string, session *Session) {
+	hub.subscribe(topic, session)
+	//session可能在注册关闭回调之前就已经关闭
+	if session.IsClosed() {
+		hub.UnsubscribeAll(session)
+	}
+}
+
+func (hub *Hub) subscribe(topic string, session *Session) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	channel, exists := hub.topics[topic]
+	if !exists {
+		channel = NewTypedChannel[uint64]()
+		channel.State = topic
+		channel.internal = true
+		hub.topics[topic] = channel
+	}
+	channel.Put(session.ID(), session)
+
+	topics, exists := hub.subs[session.ID()]
+	if !exists {
+		topics = make(map[string]struct{})
+		hub.subs[session.ID()] = topics
+		//session关闭时取消所有订阅
+		//关闭回调执行时持有session的closeMutex，而这里持有hub.mutex的时候会在Channel.Put中获取closeMutex
+		//为了避免死锁在新的goroutine中获取hub.mutex
+		session.AddCloseCallback(hub, nil, func(error) {
+			go hub.UnsubscribeAll(session)
+		})
+	}
+	if _, exists := topics[topic]; !exists {
+		topics[topic] = struct{}{}
+		session.addMembership(hub, topic)
+	}
+}
+
+//取消订阅
+func (hub *Hub) Unsubscribe(topic string, session *Session) bool {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	topics, exists := hub.subs[session.ID()]
+	if !exists {
+		return false
+	}
+	if _, exists := topics[topic]; !exists {
+		return false
+	}
+	hub.unsubscribe(topic, session)
+	delete(topics, topic)
+	session.removeMembership(hub, topic)
+	if len(topics) == 0 {
+		delete(hub.subs, session.ID())
+		session.RemoveCloseCallback(hub, nil)
+	}
+	return true
+}
+
+//取消session的所有订阅
+func (hub *Hub) UnsubscribeAll(session *Session) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	topics, exists := hub.subs[session.ID()]
+	if !exists {
+		return
+	}
+	for topic := range topics {
+		hub.unsubscribe(topic, session)
+		session.removeMembership(hub, topic)
+	}
+	delete(hub.subs, session.ID())
+	session.RemoveCloseCallback(hub, nil)
+}
+
+//从主题中删除session，没有订阅者的主题会被删除，调用时需要持有锁
+func (hub *Hub) unsubscribe(topic string, session *Session) {
+	channel, exists := hub.topics[topic]
+	if !exists {
+		return
+	}
+	//session关闭时Channel可能已经先删除了它
+	if channel.Get(session.ID()) == session {
+		channel.Remove(session.ID())
+	}
+	if channel.Len() == 0 {
+		delete(hub.topics, topic)
+	}
+}
+
+//取消订阅，用于Session.LeaveAll
+func (hub *Hub) leave(key interface{}, session *Session) bool {
+	return hub.Unsubscribe(key.(string), session)
+}
+
+//获取session订阅的主题
+func (hub *Hub) Topics(session *Session) []string {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	topics := make([]string, 0, len(hub.subs[session.ID()]))
+	for topic := range hub.subs[session.ID()] {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+//获取主题对应的Channel，主题不存在时返回nil
+func (hub *Hub) Channel(topic string) *TypedChannel[uint64] {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	return hub.topics[topic]
+}
+
+//主题的数量
+func (hub *Hub) Len() int {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	return len(hub.topics)
+}
+
+//向主题发布消息，以*结尾的主题表示发布给所有以*之前的部分为前缀的主题
+//同时订阅了多个匹配主题的session只会收到一次，返回发送成功的session数量
+//会等待所有的Send返回，同步发送的session卡住时请使用PublishContext
+func (hub *Hub) Publish(topic string, msg interface{}) int {
+	return hub.PublishContext(context.Background(), topic, msg).Sent
+}
+
+//向主题发布消息，主题的匹配规则和Publish相同，结果的key是session id
+//和Channel.BroadcastContext一样，ctx结束时立即返回，还没有发送完的session作为阻塞的session返回
+func (hub *Hub) PublishContext(ctx context.Context, topic string, msg interface{}) *BroadcastResult[uint64] {
+	var sessions []*Session
+	seen := make(map[uint64]struct{})
+	collect := func(session *Session) {
+		if _, exists := seen[session.ID()]; !exists {
+			seen[session.ID()] = struct{}{}
+			sessions = append(sessions, session)
+		}
+	}
+
+	hub.mutex.Lock()
+	if prefix := strings.TrimSuffix(topic, "*"); prefix != topic {
+		for name, channel := range hub.topics {
+			if strings.HasPrefix(name, prefix) {
+				channel.Fetch(collect)
+			}
+		}
+	} else if channel, exists := hub.topics[topic]; exists {
+		channel.Fetch(collect)
+	}
+	hub.mutex.Unlock()
+
+	return broadcast(ctx, sessions, msg, 0, (*Session).ID)
+}
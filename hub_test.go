@@ -0,0 +1,138 @@
+package link
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+func Test_Hub(t *testing.T) {
+	hub := NewHub()
+
+	codec1 := newBlockingCodec()
+	codec2 := newBlockingCodec()
+	close(codec1.release)
+	close(codec2.release)
+	session1 := NewSession(codec1, 0)
+	session2 := NewSession(codec2, 0)
+
+	hub.Subscribe("room.1", session1)
+	hub.Subscribe("room.2", session1)
+	hub.Subscribe("room.2", session2)
+	hub.Subscribe("lobby", session2)
+	utest.EqualNow(t, hub.Len(), 3)
+	utest.EqualNow(t, len(hub.Topics(session1)), 2)
+	utest.EqualNow(t, hub.Channel("room.1").State, "room.1")
+
+	utest.EqualNow(t, hub.Publish("room.2", 1), 2)
+	utest.EqualNow(t, <-codec1.sent, 1)
+	utest.EqualNow(t, <-codec2.sent, 1)
+
+	//同时订阅了room.1和room.2的session1只收到一次
+	utest.EqualNow(t, hub.Publish("room.*", 2), 2)
+	utest.EqualNow(t, <-codec1.sent, 2)
+	utest.EqualNow(t, <-codec2.sent, 2)
+	utest.EqualNow(t, len(codec1.sent), 0)
+
+	utest.Assert(t, hub.Unsubscribe("room.1", session1))
+	utest.Assert(t, !hub.Unsubscribe("room.1", session1))
+	utest.EqualNow(t, hub.Channel("room.1"), nil)
+	utest.EqualNow(t, hub.Len(), 2)
+
+	//session关闭时取消所有订阅并删除空的主题
+	session2.Close()
+	for hub.Len() != 1 {
+		runtime.Gosched()
+	}
+	utest.EqualNow(t, hub.Channel("room.2").Len(), 1)
+	utest.EqualNow(t, len(hub.Topics(session2)), 0)
+
+	session1.Close()
+	for hub.Len() != 0 {
+		runtime.Gosched()
+	}
+	utest.EqualNow(t, hub.Publish("*", 3), 0)
+
+	//订阅已经关闭的session不会留下主题
+	hub.Subscribe("lobby", session1)
+	utest.EqualNow(t, hub.Len(), 0)
+}
+
+func Test_HubPublishContext(t *testing.T) {
+	hub := NewHub()
+
+	//同步发送卡住的session不会让整个主题的发布卡住
+	stuck := newBlockingCodec()
+	codec := newBlockingCodec()
+	close(codec.release)
+	session1 := NewSession(stuck, 0)
+	session2 := NewSession(codec, 0)
+	hub.Subscribe("room", session1)
+	hub.Subscribe("room", session2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := hub.PublishContext(ctx, "room", 1)
+	utest.Assert(t, time.Since(start) < time.Second)
+	utest.EqualNow(t, result.Sent, 1)
+	utest.EqualNow(t, result.Failed[session1.ID()], context.DeadlineExceeded)
+	utest.Assert(t, reflect.DeepEqual(result.Blocked(), []uint64{session1.ID()}))
+	utest.EqualNow(t, <-codec.sent, 1)
+
+	close(stuck.release)
+	session2.Close()
+}
+
+func Test_HubSubscribeClosing(t *testing.T) {
+	hub := NewHub()
+	other := NewSession(newBlockingCodec(), 0)
+	session := NewSession(newBlockingCodec(), 0)
+	hub.Subscribe("a", session)
+	hub.Subscribe("b", other)
+
+	//在Hub的关闭回调之后执行，Hub的关闭回调被hub的锁卡住时不会执行
+	invoked := make(chan struct{})
+	session.AddCloseCallback(nil, nil, func(error) {
+		close(invoked)
+	})
+
+	//Channel的事件在Subscribe持有hub的锁的时候发出，在这里等待session关闭完成
+	subscribing := make(chan struct{})
+	deadlock := make(chan bool, 1)
+	hub.Channel("b").Subscribe(func(event ChannelEvent[uint64]) {
+		if event.Type != ChannelJoin || event.Session != session {
+			return
+		}
+		close(subscribing)
+		select {
+		case <-invoked:
+			deadlock <- false
+		case <-time.After(time.Second):
+			deadlock <- true
+		}
+	})
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		hub.Subscribe("b", session)
+	}()
+
+	<-subscribing
+	session.Close()
+	if <-deadlock {
+		t.Fatal("deadlock")
+	}
+	<-subscribed
+
+	//关闭过程中订阅的主题也会被取消
+	for hub.Len() != 1 || hub.Channel("b").Len() != 1 {
+		runtime.Gosched()
+	}
+	utest.EqualNow(t, len(hub.Topics(session)), 0)
+	other.Close()
+}
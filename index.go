@@ -0,0 +1,174 @@
+package link
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+//Manager上按照自定义key查找session的二级索引
+//和sessionMaps一样分成sessionMapNum个分片加锁，session关闭时自动解除绑定
+type Index[K comparable] struct {
+	name   string
+	unique bool
+	seed   maphash.Seed
+	maps   [sessionMapNum]indexMap[K]
+}
+
+//索引的一个分片
+type indexMap[K comparable] struct {
+	sync.RWMutex
+	sessions map[K][]*Session
+}
+
+//在manager上新建一个名为name的索引，unique为true时一个key只能绑定一个session
+//同名的索引已经存在时panic
+func NewIndex[K comparable](manager *Manager, name string, unique bool) *Index[K] {
+	index := &Index[K]{
+		name:   name,
+		unique: unique,
+		seed:   maphash.MakeSeed(),
+	}
+	for i := 0; i < len(index.maps); i++ {
+		index.maps[i].sessions = make(map[K][]*Session)
+	}
+
+	manager.indexMutex.Lock()
+	defer manager.indexMutex.Unlock()
+	if _, exists := manager.indexes[name]; exists {
+		panic("Index: duplicate index name " + name)
+	}
+	if manager.indexes == nil {
+		manager.indexes = make(map[string]interface{})
+	}
+	manager.indexes[name] = index
+	return index
+}
+
+//获取manager上名为name的索引，不存在或者key类型不匹配时返回nil
+func GetIndex[K comparable](manager *Manager, name string) *Index[K] {
+	manager.indexMutex.RLock()
+	defer manager.indexMutex.RUnlock()
+	index, _ := manager.indexes[name].(*Index[K])
+	return index
+}
+
+//索引的名字
+func (index *Index[K]) Name() string {
+	return index.name
+}
+
+//是否是唯一索引
+func (index *Index[K]) Unique() bool {
+	return index.unique
+}
+
+//获取key所在的分片
+func (index *Index[K]) indexMap(key K) *indexMap[K] {
+	return &index.maps[index.hash(key)%sessionMapNum]
+}
+
+//计算key的hash，相等的key一定得到相同的hash
+//字符串和整数按照值分片，和sessionMaps用session id分片一样，其它类型都放在第一个分片
+func (index *Index[K]) hash(key K) uint64 {
+	switch k := interface{}(key).(type) {
+	case string:
+		return maphash.String(index.seed, k)
+	case int:
+		return uint64(k)
+	case int8:
+		return uint64(k)
+	case int16:
+		return uint64(k)
+	case int32:
+		return uint64(k)
+	case int64:
+		return uint64(k)
+	case uint:
+		return uint64(k)
+	case uint8:
+		return uint64(k)
+	case uint16:
+		return uint64(k)
+	case uint32:
+		return uint64(k)
+	case uint64:
+		return k
+	case uintptr:
+		return uint64(k)
+	}
+	return 0
+}
+
+//把key绑定到session，唯一索引中key已经绑定了其它session时会被替换，返回被替换的session
+func (index *Index[K]) Bind(key K, session *Session) (old *Session) {
+	old = index.bind(key, session)
+	//session可能在注册关闭回调之前就已经关闭
+	if session.IsClosed() {
+		index.Unbind(key, session)
+	}
+	return
+}
+
+func (index *Index[K]) bind(key K, session *Session) (old *Session) {
+	imap := index.indexMap(key)
+	imap.Lock()
+	defer imap.Unlock()
+
+	sessions := imap.sessions[key]
+	for _, s := range sessions {
+		if s == session {
+			return nil
+		}
+	}
+	if index.unique && len(sessions) != 0 {
+		old = sessions[0]
+		old.RemoveCloseCallback(index, key)
+		sessions = sessions[:0]
+	}
+	session.AddCloseCallback(index, key, func(error) {
+		index.Unbind(key, session)
+	})
+	imap.sessions[key] = append(sessions, session)
+	return
+}
+
+//解除key和session的绑定
+func (index *Index[K]) Unbind(key K, session *Session) bool {
+	imap := index.indexMap(key)
+	imap.Lock()
+	defer imap.Unlock()
+
+	sessions := imap.sessions[key]
+	for i, s := range sessions {
+		if s == session {
+			session.RemoveCloseCallback(index, key)
+			if len(sessions) == 1 {
+				delete(imap.sessions, key)
+			} else {
+				imap.sessions[key] = append(sessions[:i:i], sessions[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+//获取key绑定的session，有多个时返回最早绑定的
+func (index *Index[K]) Get(key K) *Session {
+	imap := index.indexMap(key)
+	imap.RLock()
+	defer imap.RUnlock()
+
+	if sessions := imap.sessions[key]; len(sessions) != 0 {
+		return sessions[0]
+	}
+	return nil
+}
+
+//获取key绑定的所有session
+func (index *Index[K]) GetAll(key K) []*Session {
+	imap := index.indexMap(key)
+	imap.RLock()
+	defer imap.RUnlock()
+	return append([]*Session(nil), imap.sessions[key]...)
+}
@@ -0,0 +1,69 @@
+package link
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_Index(t *testing.T) {
+	manager := NewManager()
+	users := NewIndex[int64](manager, "user", true)
+	devices := NewIndex[string](manager, "device", false)
+	utest.EqualNow(t, GetIndex[int64](manager, "user"), users)
+	utest.EqualNow(t, GetIndex[string](manager, "user"), (*Index[string])(nil))
+
+	session1 := manager.NewSession(&BlockingCodec{}, 0)
+	session2 := manager.NewSession(&BlockingCodec{}, 0)
+
+	utest.EqualNow(t, users.Bind(1, session1), (*Session)(nil))
+	utest.EqualNow(t, users.Bind(1, session2), session1)
+	utest.EqualNow(t, users.Get(1), session2)
+
+	devices.Bind("ios", session1)
+	devices.Bind("ios", session2)
+	utest.EqualNow(t, len(devices.GetAll("ios")), 2)
+
+	//被替换掉的session关闭时不会解除新的绑定
+	session1.Close()
+	for len(devices.GetAll("ios")) != 1 {
+		runtime.Gosched()
+	}
+	utest.EqualNow(t, users.Get(1), session2)
+	utest.EqualNow(t, devices.Get("ios"), session2)
+
+	utest.Assert(t, users.Unbind(1, session2))
+	utest.Assert(t, !users.Unbind(1, session2))
+	utest.EqualNow(t, users.Get(1), (*Session)(nil))
+
+	session2.Close()
+	for devices.Get("ios") != nil {
+		runtime.Gosched()
+	}
+	manager.Dispose()
+}
+
+func Test_IndexKeyTypes(t *testing.T) {
+	type pair struct{ a, b int }
+
+	manager := NewManager()
+	pairs := NewIndex[pair](manager, "pair", true)
+	keys := NewIndex[KEY](manager, "key", true)
+	session := manager.NewSession(&BlockingCodec{}, 0)
+
+	pairs.Bind(pair{1, 2}, session)
+	keys.Bind("a", session)
+	keys.Bind(1, session)
+	utest.EqualNow(t, pairs.Get(pair{1, 2}), session)
+	utest.EqualNow(t, pairs.Get(pair{2, 1}), (*Session)(nil))
+	utest.EqualNow(t, keys.Get("a"), session)
+	utest.EqualNow(t, keys.Get(1), session)
+	utest.EqualNow(t, keys.Get(int64(1)), (*Session)(nil))
+
+	session.Close()
+	for keys.Get("a") != nil || pairs.Get(pair{1, 2}) != nil {
+		runtime.Gosched()
+	}
+	manager.Dispose()
+}
@@ -0,0 +1,72 @@
+package link
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+//超过IdleTimeout没有收到消息时的关闭原因
+var SessionIdleError = errors.New("Session Idle")
+
+//心跳设置，心跳消息的格式由使用者决定，所以可以用于任意的Codec
+type Keepalive struct {
+	Interval    time.Duration      //发送心跳和检查超时的间隔，小于等于0时使用IdleTimeout的一半
+	IdleTimeout time.Duration      //超过这个时间没有收到任何消息就关闭session，小于等于0表示不检查
+	Ping        func() interface{} //生成心跳消息，为nil时不发送心跳
+
+	//判断收到的消息是否是心跳消息，是心跳消息时Receive不会返回这个消息
+	//reply不为nil时会在Receive中发送给对方，用于应答对方的心跳
+	Pong func(msg interface{}) (reply interface{}, ok bool)
+}
+
+//启动心跳，需要在开始收发消息之前调用，只能调用一次
+func (session *Session) StartKeepalive(keepalive Keepalive) {
+	if keepalive.Interval <= 0 {
+		keepalive.Interval = keepalive.IdleTimeout / 2
+	}
+	session.keepalive = &keepalive
+	if keepalive.Interval > 0 {
+		go session.keepaliveLoop(session.keepalive)
+	}
+}
+
+//最后一次收到消息的时间，没有收到过消息时是session的创建时间
+func (session *Session) LastReceive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&session.lastRecv))
+}
+
+//记录收到消息的时间，返回是否是心跳消息
+func (session *Session) received(msg interface{}) bool {
+	atomic.StoreInt64(&session.lastRecv, time.Now().UnixNano())
+
+	keepalive := session.keepalive
+	if keepalive == nil || keepalive.Pong == nil {
+		return false
+	}
+	reply, ok := keepalive.Pong(msg)
+	if ok && reply != nil {
+		session.Send(reply)
+	}
+	return ok
+}
+
+//定时发送心跳并检查是否超时
+func (session *Session) keepaliveLoop(keepalive *Keepalive) {
+	ticker := time.NewTicker(keepalive.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if keepalive.IdleTimeout > 0 && time.Since(session.LastReceive()) > keepalive.IdleTimeout {
+				session.CloseWithReason(SessionIdleError)
+				return
+			}
+			if keepalive.Ping != nil && session.Send(keepalive.Ping()) != nil {
+				return
+			}
+		case <-session.closeChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package link
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+func Test_KeepaliveIdle(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	defer conn2.Close()
+
+	codec, _ := NewTestCodec(conn1)
+	session := NewSession(codec, 0)
+	session.StartKeepalive(Keepalive{
+		IdleTimeout: 30 * time.Millisecond,
+	})
+
+	_, err := session.Receive()
+	utest.Assert(t, err != nil)
+	utest.EqualNow(t, session.CloseReason(), SessionIdleError)
+}
+
+func Test_KeepalivePingPong(t *testing.T) {
+	ping, pong := []byte("ping"), []byte("pong")
+	keepalive := Keepalive{
+		Interval:    10 * time.Millisecond,
+		IdleTimeout: 50 * time.Millisecond,
+		Ping: func() interface{} {
+			return ping
+		},
+		Pong: func(msg interface{}) (interface{}, bool) {
+			switch {
+			case bytes.Equal(msg.([]byte), ping):
+				return pong, true
+			case bytes.Equal(msg.([]byte), pong):
+				return nil, true
+			}
+			return nil, false
+		},
+	}
+
+	conn1, conn2 := net.Pipe()
+	codec1, _ := NewTestCodec(conn1)
+	codec2, _ := NewTestCodec(conn2)
+	session1 := NewSession(codec1, 10)
+	session2 := NewSession(codec2, 10)
+	session1.StartKeepalive(keepalive)
+	session2.StartKeepalive(keepalive)
+
+	received := make(chan interface{}, 2)
+	for _, session := range []*Session{session1, session2} {
+		go func(session *Session) {
+			msg, _ := session.Receive()
+			received <- msg
+		}(session)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	utest.Assert(t, !session1.IsClosed())
+	utest.Assert(t, !session2.IsClosed())
+	utest.Assert(t, time.Since(session1.LastReceive()) < 50*time.Millisecond)
+
+	//心跳消息不会被Receive返回
+	select {
+	case msg := <-received:
+		t.Fatalf("unexpected message: %v", msg)
+	default:
+	}
+
+	session1.Close()
+	session2.Close()
+}
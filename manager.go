@@ -1,14 +1,31 @@
 package link
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 const sessionMapNum = 32
 
+//Manager销毁时关闭session的关闭原因
+var ManagerDisposedError = errors.New("Manager Disposed")
+
 //session的管理
 type Manager struct {
 	sessionMaps [sessionMapNum]sessionMap
 	disposeOnce sync.Once
 	disposeWait sync.WaitGroup
+	disposeDone chan struct{} //所有session都已经移除
+	count       int64         //session的数量
+
+	indexMutex sync.RWMutex
+	indexes    map[string]interface{} //名字对应的*Index[K]
+
+	observerMutex sync.Mutex   //保护AddObserver
+	observers     atomic.Value //[]Observer，新增时整体替换
 }
 
 //session的基本信息
@@ -20,35 +37,65 @@ type sessionMap struct {
 
 //新建一个Manager，含有多个sessionMapNum个 sessionMap
 func NewManager() *Manager {
-	manager := &Manager{}
+	manager := &Manager{
+		disposeDone: make(chan struct{}),
+	}
 	for i := 0; i < len(manager.sessionMaps); i++ {
 		manager.sessionMaps[i].sessions = make(map[uint64]*Session)
 	}
 	return manager
 }
 
-//只做一次
+//销毁Manager，关闭所有的session并等待所有session移除
 func (manager *Manager) Dispose() {
+	manager.DisposeContext(context.Background())
+}
+
+//销毁Manager，关闭所有的session并等待所有session移除，ctx结束时不再等待
+//返回还没有移除的session数量，ctx结束时同时返回ctx的错误
+func (manager *Manager) DisposeContext(ctx context.Context) (int, error) {
+	//关闭只做一次
 	manager.disposeOnce.Do(func() {
+		var sessions []*Session
 		for i := 0; i < sessionMapNum; i++ {
 			//获取当前的sessionMap
 			smap := &manager.sessionMaps[i]
 			smap.Lock()
 			smap.disposed = true //关闭
-			//关闭当个sessionMap
+			sessions = sessions[:0]
 			for _, session := range smap.sessions {
-				session.Close()
+				sessions = append(sessions, session)
 			}
 			smap.Unlock()
+
+			//关闭当个sessionMap，不在持有锁的时候关闭
+			for _, session := range sessions {
+				session.CloseWithReason(ManagerDisposedError)
+			}
 		}
 		//等待线程组的结束
-		manager.disposeWait.Wait()
+		go func() {
+			manager.disposeWait.Wait()
+			close(manager.disposeDone)
+		}()
 	})
+
+	select {
+	case <-manager.disposeDone:
+		return 0, nil
+	case <-ctx.Done():
+		return manager.Len(), ctx.Err()
+	}
 }
 
 //新建一个session，把session放入manager
 func (manager *Manager) NewSession(codec Codec, sendChanSize int) *Session {
-	session := newSession(manager, codec, sendChanSize)
+	return manager.newSession(codec, sendChanSize, BackPressureClose, 0)
+}
+
+//新建一个使用指定背压策略的session，策略在放入manager之前设置，避免和Range、Broadcast中的Send竞争
+func (manager *Manager) newSession(codec Codec, sendChanSize int, policy BackPressure, blockTimeout time.Duration) *Session {
+	session := newSession(manager, codec, sendChanSize, policy, blockTimeout)
 	manager.putSession(session)
 	return session
 }
@@ -63,6 +110,54 @@ func (manager *Manager) GetSession(sessionID uint64) *Session {
 	return session
 }
 
+//session的数量
+func (manager *Manager) Len() int {
+	return int(atomic.LoadInt64(&manager.count))
+}
+
+//遍历所有的session，callback返回false时停止遍历
+//逐个sessionMap复制session列表之后再调用callback，不会在调用callback时持有锁
+func (manager *Manager) Range(callback func(*Session) bool) {
+	var sessions []*Session
+	for i := 0; i < sessionMapNum; i++ {
+		smap := &manager.sessionMaps[i]
+		smap.RLock()
+		sessions = sessions[:0]
+		for _, session := range smap.sessions {
+			sessions = append(sessions, session)
+		}
+		smap.RUnlock()
+
+		for _, session := range sessions {
+			if !callback(session) {
+				return
+			}
+		}
+	}
+}
+
+//把消息发送给所有的session，每个session一个goroutine，结果的key是session id
+//会等待所有的Send返回，同步发送的session卡住时请使用BroadcastContext
+func (manager *Manager) Broadcast(msg interface{}) *BroadcastResult[uint64] {
+	return manager.BroadcastN(msg, 0)
+}
+
+//把消息发送给所有的session，最多同时使用workers个goroutine，小于等于0时不限制
+func (manager *Manager) BroadcastN(msg interface{}, workers int) *BroadcastResult[uint64] {
+	return manager.BroadcastContext(context.Background(), msg, workers)
+}
+
+//把消息发送给所有的session，ctx结束时立即返回，还没有发送完的session作为阻塞的session返回
+//异步发送的session按照各自的背压策略处理，同步发送被ctx中断的session会被关闭
+func (manager *Manager) BroadcastContext(ctx context.Context, msg interface{}, workers int) *BroadcastResult[uint64] {
+	sessions := make([]*Session, 0, manager.Len())
+	manager.Range(func(session *Session) bool {
+		sessions = append(sessions, session)
+		return true
+	})
+	return broadcast(ctx, sessions, msg, workers, (*Session).ID)
+}
+
 //根据session_id放入session
 func (manager *Manager) putSession(session *Session) {
 	smap := &manager.sessionMaps[session.id%sessionMapNum]
@@ -71,11 +166,12 @@ func (manager *Manager) putSession(session *Session) {
 	defer smap.Unlock()
 
 	if smap.disposed {
-		session.Close()
+		session.CloseWithReason(ManagerDisposedError)
 		return
 	}
 
 	smap.sessions[session.id] = session
+	atomic.AddInt64(&manager.count, 1)
 	//增加一个Add+1
 	manager.disposeWait.Add(1)
 }
@@ -87,7 +183,12 @@ func (manager *Manager) delSession(session *Session) {
 	smap.Lock()
 	defer smap.Unlock()
 
+	//Manager销毁之后新建的session没有放入sessionMap
+	if _, exists := smap.sessions[session.id]; !exists {
+		return
+	}
 	delete(smap.sessions, session.id)
+	atomic.AddInt64(&manager.count, -1)
 	//增加一个done-1
 	manager.disposeWait.Done()
 }
@@ -0,0 +1,74 @@
+package link
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+func Test_ManagerRange(t *testing.T) {
+	manager := NewManager()
+	codec := newBlockingCodec()
+	close(codec.release)
+
+	for i := 0; i < 100; i++ {
+		manager.NewSession(codec, 0)
+	}
+	utest.EqualNow(t, manager.Len(), 100)
+
+	n := 0
+	manager.Range(func(session *Session) bool {
+		n++
+		return n < 10
+	})
+	utest.EqualNow(t, n, 10)
+
+	result := manager.Broadcast(1)
+	utest.EqualNow(t, result.Sent, 100)
+	utest.EqualNow(t, len(codec.sent), 100)
+
+	manager.Range(func(session *Session) bool {
+		session.Close()
+		return false
+	})
+	for manager.Len() != 99 {
+		runtime.Gosched()
+	}
+
+	manager.Dispose()
+	utest.EqualNow(t, manager.Len(), 0)
+
+	//销毁之后新建的session会被直接关闭
+	session := manager.NewSession(codec, 0)
+	utest.Assert(t, session.IsClosed())
+	utest.EqualNow(t, manager.Len(), 0)
+}
+
+func Test_DisposeContext(t *testing.T) {
+	manager := NewManager()
+	for i := 0; i < 10; i++ {
+		manager.NewSession(&BlockingCodec{}, 0)
+	}
+
+	//关闭回调卡住的session
+	release := make(chan struct{})
+	session := manager.NewSession(&BlockingCodec{}, 0)
+	session.AddCloseCallback(nil, nil, func(error) {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	remain, err := manager.DisposeContext(ctx)
+	utest.EqualNow(t, err, context.DeadlineExceeded)
+	utest.EqualNow(t, remain, 1)
+	utest.EqualNow(t, session.CloseReason(), ManagerDisposedError)
+
+	close(release)
+	remain, err = manager.DisposeContext(context.Background())
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, remain, 0)
+}
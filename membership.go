@@ -0,0 +1,50 @@
+package link
+
+//session所在的分组
+type Membership struct {
+	Group interface{} //session所在的分组，类型是*TypedChannel[K]或者*Hub
+	Key   interface{} //session在分组中的key，Hub中是主题名
+}
+
+//可以删除成员的分组，TypedChannel和Hub实现了这个接口
+type memberGroup interface {
+	leave(key interface{}, session *Session) bool
+}
+
+//记录session加入了分组
+func (session *Session) addMembership(group memberGroup, key interface{}) {
+	session.groupMutex.Lock()
+	defer session.groupMutex.Unlock()
+	session.groups = append(session.groups, Membership{group, key})
+}
+
+//记录session离开了分组
+func (session *Session) removeMembership(group memberGroup, key interface{}) {
+	session.groupMutex.Lock()
+	defer session.groupMutex.Unlock()
+	for i, membership := range session.groups {
+		if membership.Group == group && membership.Key == key {
+			session.groups = append(session.groups[:i], session.groups[i+1:]...)
+			return
+		}
+	}
+}
+
+//获取session所在的所有分组，用于诊断
+//通过Hub订阅的每个主题只列出一次，Group是Hub
+func (session *Session) Memberships() []Membership {
+	session.groupMutex.Lock()
+	defer session.groupMutex.Unlock()
+	return append([]Membership(nil), session.groups...)
+}
+
+//离开所有的分组但不关闭session，返回离开的分组数量
+func (session *Session) LeaveAll() int {
+	n := 0
+	for _, membership := range session.Memberships() {
+		if membership.Group.(memberGroup).leave(membership.Key, session) {
+			n++
+		}
+	}
+	return n
+}
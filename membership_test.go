@@ -0,0 +1,35 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_Membership(t *testing.T) {
+	channel1 := NewChannel()
+	channel2 := NewTypedChannel[string]()
+	hub := NewHub()
+
+	session := newSession(nil, &BlockingCodec{}, 0, BackPressureClose, 0)
+	channel1.Put(1, session)
+	channel2.Put("a", session)
+	channel2.Put("a", session)
+	hub.Subscribe("room", session)
+
+	//重复放入只记录一次，Hub的主题不会再列出主题对应的Channel
+	memberships := session.Memberships()
+	utest.EqualNow(t, len(memberships), 3)
+	utest.EqualNow(t, memberships[0], Membership{channel1, 1})
+	utest.EqualNow(t, memberships[1], Membership{channel2, "a"})
+	utest.EqualNow(t, memberships[2], Membership{hub, "room"})
+
+	utest.Assert(t, channel2.Remove("a"))
+	utest.EqualNow(t, len(session.Memberships()), 2)
+
+	utest.EqualNow(t, session.LeaveAll(), 2)
+	utest.EqualNow(t, len(session.Memberships()), 0)
+	utest.EqualNow(t, channel1.Len(), 0)
+	utest.EqualNow(t, hub.Len(), 0)
+	utest.Assert(t, !session.IsClosed())
+}
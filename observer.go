@@ -0,0 +1,81 @@
+package link
+
+//session生命周期的观察者，用于日志、审计和统计
+//回调在产生事件的goroutine中同步调用，不应该长时间阻塞
+type Observer interface {
+	OnCreate(session *Session)                   //新建session时调用，这时session还没有放入Manager
+	OnClose(session *Session, reason error)      //session关闭时调用，在关闭回调之前
+	OnSendError(session *Session, err error)     //Codec.Send出错时调用，随后session会被关闭
+	OnReceiveError(session *Session, err error)  //Codec.Receive出错时调用，随后session会被关闭
+	OnBlocked(session *Session, msg interface{}) //异步发送队列满时调用，之后按照背压策略处理msg，可以在这里关闭session
+}
+
+//空的观察者，嵌入到结构体中可以只实现需要的方法
+type NopObserver struct{}
+
+func (NopObserver) OnCreate(session *Session)                   {}
+func (NopObserver) OnClose(session *Session, reason error)      {}
+func (NopObserver) OnSendError(session *Session, err error)     {}
+func (NopObserver) OnReceiveError(session *Session, err error)  {}
+func (NopObserver) OnBlocked(session *Session, msg interface{}) {}
+
+//增加一个观察者，只对之后产生的事件有效
+func (manager *Manager) AddObserver(observer Observer) {
+	manager.observerMutex.Lock()
+	defer manager.observerMutex.Unlock()
+
+	//复制一份再替换，通知的时候不需要加锁
+	old := manager.getObservers()
+	observers := make([]Observer, len(old), len(old)+1)
+	copy(observers, old)
+	manager.observers.Store(append(observers, observer))
+}
+
+//获取当前的观察者列表
+func (manager *Manager) getObservers() []Observer {
+	observers, _ := manager.observers.Load().([]Observer)
+	return observers
+}
+
+//获取session所属Manager的观察者，不属于任何Manager的session没有观察者
+func (session *Session) observers() []Observer {
+	if session.manager == nil {
+		return nil
+	}
+	return session.manager.getObservers()
+}
+
+//通知新建session
+func (session *Session) notifyCreate() {
+	for _, observer := range session.observers() {
+		observer.OnCreate(session)
+	}
+}
+
+//通知session关闭
+func (session *Session) notifyClose(reason error) {
+	for _, observer := range session.observers() {
+		observer.OnClose(session, reason)
+	}
+}
+
+//通知发送出错
+func (session *Session) notifySendError(err error) {
+	for _, observer := range session.observers() {
+		observer.OnSendError(session, err)
+	}
+}
+
+//通知接收出错
+func (session *Session) notifyReceiveError(err error) {
+	for _, observer := range session.observers() {
+		observer.OnReceiveError(session, err)
+	}
+}
+
+//通知发送队列已满
+func (session *Session) notifyBlocked(msg interface{}) {
+	for _, observer := range session.observers() {
+		observer.OnBlocked(session, msg)
+	}
+}
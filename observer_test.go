@@ -0,0 +1,130 @@
+package link
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+type TestObserver struct {
+	NopObserver
+	sync.Mutex
+	events []string
+	closed chan error
+}
+
+func (o *TestObserver) add(event string) {
+	o.Lock()
+	defer o.Unlock()
+	o.events = append(o.events, event)
+}
+
+func (o *TestObserver) OnCreate(session *Session)                   { o.add("create") }
+func (o *TestObserver) OnSendError(session *Session, err error)     { o.add("send") }
+func (o *TestObserver) OnReceiveError(session *Session, err error)  { o.add("receive") }
+func (o *TestObserver) OnBlocked(session *Session, msg interface{}) { o.add("blocked") }
+
+func (o *TestObserver) OnClose(session *Session, reason error) {
+	o.add("close")
+	o.closed <- reason
+}
+
+func (o *TestObserver) Events() []string {
+	o.Lock()
+	defer o.Unlock()
+	return append([]string(nil), o.events...)
+}
+
+type ErrorCodec struct {
+	err error
+}
+
+func (c *ErrorCodec) Send(msg interface{}) error {
+	return c.err
+}
+
+func (c *ErrorCodec) Receive() (interface{}, error) {
+	return nil, c.err
+}
+
+func (c *ErrorCodec) Close() error {
+	return nil
+}
+
+func Test_Observer(t *testing.T) {
+	manager := NewManager()
+	observer := &TestObserver{closed: make(chan error, 1)}
+	manager.AddObserver(observer)
+
+	//接收出错
+	session := manager.NewSession(&ErrorCodec{io.EOF}, 0)
+	_, err := session.Receive()
+	utest.EqualNow(t, err, io.EOF)
+	utest.EqualNow(t, <-observer.closed, io.EOF)
+	utest.EqualNow(t, observer.Events(), []string{"create", "receive", "close"})
+
+	//发送出错
+	observer.events = nil
+	sendErr := errors.New("send error")
+	session = manager.NewSession(&ErrorCodec{sendErr}, 1)
+	utest.IsNilNow(t, session.Send(1))
+	utest.EqualNow(t, <-observer.closed, sendErr)
+	utest.EqualNow(t, observer.Events(), []string{"create", "send", "close"})
+
+	//发送队列满
+	observer.events = nil
+	codec := newBlockingCodec()
+	session = manager.NewSession(codec, 1)
+	utest.IsNilNow(t, session.Send(1))
+	for len(session.sendChan) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	utest.IsNilNow(t, session.Send(2))
+	utest.EqualNow(t, session.Send(3), SessionBlockedError)
+	utest.EqualNow(t, <-observer.closed, SessionBlockedError)
+	utest.EqualNow(t, observer.Events(), []string{"create", "blocked", "close"})
+	close(codec.release)
+
+	manager.Dispose()
+}
+
+//在OnBlocked中关闭发送阻塞的session
+type KickObserver struct {
+	NopObserver
+}
+
+func (KickObserver) OnBlocked(session *Session, msg interface{}) {
+	session.Close()
+}
+
+func Test_ObserverBlockedClose(t *testing.T) {
+	manager := NewManager()
+	manager.AddObserver(KickObserver{})
+
+	codec := newBlockingCodec()
+	session := manager.NewSession(codec, 1)
+	utest.IsNilNow(t, session.Send(1))
+	for len(session.sendChan) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	utest.IsNilNow(t, session.Send(2))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Send(3)
+	}()
+	select {
+	case err := <-done:
+		utest.EqualNow(t, err, SessionClosedError)
+	case <-time.After(time.Second):
+		t.Fatal("deadlock")
+	}
+	utest.Assert(t, session.IsClosed())
+	close(codec.release)
+
+	manager.Dispose()
+}
@@ -0,0 +1,122 @@
+package link
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+)
+
+//处理单个消息的函数
+type MessageHandler func(session *Session, msg interface{})
+
+//包装MessageHandler的中间件
+type Middleware func(MessageHandler) MessageHandler
+
+//根据注册的名字查找消息类型，codec.JsonProtocol实现了这个接口
+type TypeRegistry interface {
+	NameType(name string) (reflect.Type, bool)
+}
+
+//处理消息时发生的panic
+type PanicError struct {
+	Value interface{} //recover()的返回值
+	Stack []byte      //panic时的调用栈
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("link: panic: %v", err.Value)
+}
+
+//定义一个空Router
+var _ Handler = (*Router)(nil)
+
+//按照消息类型分发消息的Handler
+//注册需要在开始处理session之前完成
+type Router struct {
+	handlers map[reflect.Type]MessageHandler //消息类型对应的处理函数
+	fallback MessageHandler                  //未注册类型的处理函数
+	onPanic  func(*Session, interface{}, *PanicError)
+}
+
+//新建一个Router
+func NewRouter() *Router {
+	return &Router{
+		handlers: make(map[reflect.Type]MessageHandler),
+	}
+}
+
+//按照msg的类型注册处理函数，指针和非指针类型视为同一种类型
+//middlewares按顺序从外到内包装handler
+func (router *Router) Handle(msg interface{}, handler MessageHandler, middlewares ...Middleware) {
+	router.HandleType(reflect.TypeOf(msg), handler, middlewares...)
+}
+
+//按照类型注册处理函数
+func (router *Router) HandleType(t reflect.Type, handler MessageHandler, middlewares ...Middleware) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	router.handlers[t] = handler
+}
+
+//按照registry中注册的名字注册处理函数，名字不存在时panic
+func (router *Router) HandleName(registry TypeRegistry, name string, handler MessageHandler, middlewares ...Middleware) {
+	t, exists := registry.NameType(name)
+	if !exists {
+		panic("Router: unknown message name " + name)
+	}
+	router.HandleType(t, handler, middlewares...)
+}
+
+//设置未注册类型的处理函数，没有设置时丢弃消息
+func (router *Router) Fallback(handler MessageHandler, middlewares ...Middleware) {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	router.fallback = handler
+}
+
+//设置处理函数panic时的回调，panic之后会继续处理后续消息
+func (router *Router) OnPanic(callback func(session *Session, msg interface{}, err *PanicError)) {
+	router.onPanic = callback
+}
+
+//循环接收消息并分发，直到接收出错
+func (router *Router) HandleSession(session *Session) {
+	for {
+		msg, err := session.Receive()
+		if err != nil {
+			return
+		}
+		router.Dispatch(session, msg)
+	}
+}
+
+//把消息分发给对应的处理函数
+func (router *Router) Dispatch(session *Session, msg interface{}) {
+	handler := router.fallback
+	if msg != nil {
+		t := reflect.TypeOf(msg)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if h, exists := router.handlers[t]; exists {
+			handler = h
+		}
+	}
+	if handler == nil {
+		return
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			if router.onPanic != nil {
+				router.onPanic(session, msg, &PanicError{v, debug.Stack()})
+			}
+		}
+	}()
+	handler(session, msg)
+}
@@ -0,0 +1,60 @@
+package link
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+type RouterMsg1 struct{ N int }
+
+type RouterMsg2 struct{ S string }
+
+type TestRegistry map[string]reflect.Type
+
+func (r TestRegistry) NameType(name string) (reflect.Type, bool) {
+	t, exists := r[name]
+	return t, exists
+}
+
+func Test_Router(t *testing.T) {
+	var trace []string
+	middleware := func(name string) Middleware {
+		return func(next MessageHandler) MessageHandler {
+			return func(session *Session, msg interface{}) {
+				trace = append(trace, name)
+				next(session, msg)
+			}
+		}
+	}
+
+	router := NewRouter()
+	router.Handle(RouterMsg1{}, func(session *Session, msg interface{}) {
+		trace = append(trace, "msg1")
+		if msg.(*RouterMsg1).N < 0 {
+			panic("negative")
+		}
+	}, middleware("a"), middleware("b"))
+	router.HandleName(TestRegistry{"msg2": reflect.TypeOf(RouterMsg2{})}, "msg2", func(session *Session, msg interface{}) {
+		trace = append(trace, "msg2")
+	})
+	router.Fallback(func(session *Session, msg interface{}) {
+		trace = append(trace, "fallback")
+	})
+
+	var panicErr *PanicError
+	router.OnPanic(func(session *Session, msg interface{}, err *PanicError) {
+		panicErr = err
+	})
+
+	session := newSession(nil, &BlockingCodec{}, 0, BackPressureClose, 0)
+	router.Dispatch(session, &RouterMsg1{1})
+	router.Dispatch(session, RouterMsg2{"x"})
+	router.Dispatch(session, 123)
+	router.Dispatch(session, &RouterMsg1{-1})
+
+	utest.Assert(t, reflect.DeepEqual(trace, []string{"a", "b", "msg1", "msg2", "fallback", "a", "b", "msg1"}))
+	utest.Assert(t, panicErr != nil)
+	utest.EqualNow(t, panicErr.Value, "negative")
+}
@@ -1,15 +1,48 @@
 package link
 
-import "net"
+import (
+	"context"
+	"net"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+//服务的参数
+type ServerOptions struct {
+	SendChanSize int           //发送chan的大小，0表示同步发送
+	BackPressure BackPressure  //发送队列满时的处理策略
+	BlockTimeout time.Duration //BackPressureBlock的等待时间
+
+	MaxSessions      int //同时存在的最大连接数，0表示不限制
+	MaxSessionsPerIP int //同一个IP同时存在的最大连接数，0表示不限制
+
+	OnAccept         func(conn net.Conn) bool //在NewCodec之前调用，返回false时关闭连接
+	OnSessionCreated func(session *Session)   //在HandleSession之前调用
+	OnShutdown       func(session *Session)   //Shutdown时对每个session调用，用于通知session结束处理
+
+	OnPanic      func(session *Session, err *PanicError) //HandleSession发生panic时调用，session已经用err作为关闭原因关闭
+	OnCodecError func(conn net.Conn, err error)          //NewCodec失败时调用，conn已经关闭
+
+	Observer Observer //session生命周期的观察者，为nil时不观察
+}
 
 //服务的struct
 type Server struct {
 	manager  *Manager     //一个manager
 	listener net.Listener //一个listener
 
-	protocol     Protocol //接收读写的协议接口
-	handler      Handler  //处理session的接口
-	sendChanSize int      //发送chan的大小
+	protocol Protocol      //接收读写的协议接口
+	handler  Handler       //处理session的接口
+	options  ServerOptions //服务的参数
+
+	connMutex sync.Mutex     //连接数的锁
+	connCount int            //当前的连接数
+	ipCounts  map[string]int //每个IP当前的连接数
+
+	handlerMutex sync.Mutex     //保护shutdown和handlerWait.Add
+	handlerWait  sync.WaitGroup //正在执行的HandleSession
+	shutdown     bool           //是否已经开始Shutdown
 }
 
 //处理session
@@ -29,12 +62,24 @@ func (f HandlerFunc) HandleSession(session *Session) {
 
 //新建一个server
 func NewServer(listener net.Listener, protocol Protocol, sendChanSize int, handler Handler) *Server {
+	return NewServerWithOptions(listener, protocol, handler, ServerOptions{
+		SendChanSize: sendChanSize,
+	})
+}
+
+//根据参数新建一个server
+func NewServerWithOptions(listener net.Listener, protocol Protocol, handler Handler, options ServerOptions) *Server {
+	manager := NewManager()
+	if options.Observer != nil {
+		manager.AddObserver(options.Observer)
+	}
 	return &Server{
-		manager:      NewManager(),
-		listener:     listener,
-		protocol:     protocol,
-		handler:      handler,
-		sendChanSize: sendChanSize,
+		manager:  manager,
+		listener: listener,
+		protocol: protocol,
+		handler:  handler,
+		options:  options,
+		ipCounts: make(map[string]int),
 	}
 }
 
@@ -43,6 +88,12 @@ func (server *Server) Listener() net.Listener {
 	return server.listener
 }
 
+//设置新建session的发送队列满时的处理策略，需要在Serve之前调用
+func (server *Server) SetBackPressure(policy BackPressure, timeout time.Duration) {
+	server.options.BackPressure = policy
+	server.options.BlockTimeout = timeout
+}
+
 //监听服务
 func (server *Server) Serve() error {
 	for {
@@ -51,26 +102,184 @@ func (server *Server) Serve() error {
 			return err
 		}
 
+		//已经开始Shutdown的时候不再处理新连接
+		if !server.addHandler() {
+			conn.Close()
+			continue
+		}
+
+		//超过连接数限制的连接直接关闭
+		ip, ok := server.acquireConn(conn)
+		if !ok {
+			conn.Close()
+			server.handlerWait.Done()
+			continue
+		}
+
 		go func() {
+			defer server.handlerWait.Done()
+
+			if server.options.OnAccept != nil && !server.options.OnAccept(conn) {
+				conn.Close()
+				server.releaseConn(ip)
+				return
+			}
 			//返回一个Codec接口类型
 			codec, err := server.protocol.NewCodec(conn)
 			if err != nil {
 				conn.Close()
+				server.releaseConn(ip)
+				if server.options.OnCodecError != nil {
+					server.options.OnCodecError(conn, err)
+				}
 				return
 			}
 			//新建一个session
-			session := server.manager.NewSession(codec, server.sendChanSize)
+			session := server.manager.newSession(codec, server.options.SendChanSize, server.options.BackPressure, server.options.BlockTimeout)
+
+			//session关闭时释放连接数，session可能在注册回调之前就已经关闭
+			var releaseOnce sync.Once
+			release := func(error) {
+				releaseOnce.Do(func() {
+					server.releaseConn(ip)
+				})
+			}
+			session.AddCloseCallback(server, nil, release)
+			if session.IsClosed() {
+				release(nil)
+			}
+
 			//处理session
-			server.handler.HandleSession(session)
+			server.handleSession(session)
 		}()
 	}
 }
 
+//处理session，panic时关闭session并调用OnPanic
+func (server *Server) handleSession(session *Session) {
+	defer func() {
+		if v := recover(); v != nil {
+			err := &PanicError{v, debug.Stack()}
+			session.CloseWithReason(err)
+			if server.options.OnPanic != nil {
+				server.options.OnPanic(session, err)
+			}
+		}
+	}()
+
+	if server.options.OnSessionCreated != nil {
+		server.options.OnSessionCreated(session)
+	}
+	server.handler.HandleSession(session)
+}
+
+//增加一个正在执行的HandleSession，已经开始Shutdown时返回false
+func (server *Server) addHandler() bool {
+	server.handlerMutex.Lock()
+	defer server.handlerMutex.Unlock()
+	if server.shutdown {
+		return false
+	}
+	server.handlerWait.Add(1)
+	return true
+}
+
+//增加连接数，超过限制时返回false
+func (server *Server) acquireConn(conn net.Conn) (string, bool) {
+	ip := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	server.connMutex.Lock()
+	defer server.connMutex.Unlock()
+
+	if server.options.MaxSessions > 0 && server.connCount >= server.options.MaxSessions {
+		return ip, false
+	}
+	if server.options.MaxSessionsPerIP > 0 && server.ipCounts[ip] >= server.options.MaxSessionsPerIP {
+		return ip, false
+	}
+	server.connCount++
+	server.ipCounts[ip]++
+	return ip, true
+}
+
+//减少连接数
+func (server *Server) releaseConn(ip string) {
+	server.connMutex.Lock()
+	defer server.connMutex.Unlock()
+
+	server.connCount--
+	if server.ipCounts[ip]--; server.ipCounts[ip] <= 0 {
+		delete(server.ipCounts, ip)
+	}
+}
+
 //获取session
 func (server *Server) GetSession(sessionID uint64) *Session {
 	return server.manager.GetSession(sessionID)
 }
 
+//获取管理session的Manager
+func (server *Server) Manager() *Manager {
+	return server.manager
+}
+
+//当前的session数量
+func (server *Server) Len() int {
+	return server.manager.Len()
+}
+
+//遍历所有的session，callback返回false时停止遍历
+func (server *Server) Range(callback func(*Session) bool) {
+	server.manager.Range(callback)
+}
+
+//把消息发送给所有的session，结果的key是session id
+func (server *Server) Broadcast(msg interface{}) *BroadcastResult[uint64] {
+	return server.manager.Broadcast(msg)
+}
+
+//把消息发送给所有的session，ctx结束时立即返回，还没有发送完的session作为阻塞的session返回
+//异步发送的session按照各自的背压策略处理，同步发送被ctx中断的session会被关闭
+func (server *Server) BroadcastContext(ctx context.Context, msg interface{}, workers int) *BroadcastResult[uint64] {
+	return server.manager.BroadcastContext(ctx, msg, workers)
+}
+
+//优雅的停止服务，停止接收新连接，对每个session调用OnShutdown，然后等待所有HandleSession返回
+//ctx结束时强制关闭剩余的session并返回ctx的错误
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.handlerMutex.Lock()
+	server.shutdown = true
+	server.handlerMutex.Unlock()
+	server.listener.Close()
+
+	if server.options.OnShutdown != nil {
+		server.manager.Range(func(session *Session) bool {
+			server.options.OnShutdown(session)
+			return true
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		server.handlerWait.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if _, disposeErr := server.manager.DisposeContext(ctx); err == nil {
+		err = disposeErr
+	}
+	return err
+}
+
 //停止服务
 func (server *Server) Stop() {
 	server.listener.Close()
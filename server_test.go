@@ -0,0 +1,230 @@
+package link
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+func Test_ServerOptions(t *testing.T) {
+	created := make(chan *Session, 10)
+	rejectNext := make(chan bool, 1)
+	server, err := ListenWithOptions("tcp", "127.0.0.1:0", ProtocolFunc(NewTestCodec), HandlerFunc(func(session *Session) {
+		session.Receive()
+	}), ServerOptions{
+		MaxSessions: 1,
+		OnAccept: func(conn net.Conn) bool {
+			select {
+			case <-rejectNext:
+				return false
+			default:
+				return true
+			}
+		},
+		OnSessionCreated: func(session *Session) {
+			created <- session
+		},
+	})
+	utest.IsNilNow(t, err)
+	go server.Serve()
+	addr := server.Listener().Addr().String()
+
+	client1, err := Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	<-created
+
+	//超过最大连接数
+	client2, err := Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	_, err = client2.Receive()
+	utest.Assert(t, err != nil)
+
+	client1.Close()
+	for {
+		server.connMutex.Lock()
+		count := server.connCount
+		server.connMutex.Unlock()
+		if count == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	//被OnAccept拒绝
+	rejectNext <- true
+	client3, err := Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	_, err = client3.Receive()
+	utest.Assert(t, err != nil)
+
+	client4, err := Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	<-created
+	client4.Close()
+
+	server.Stop()
+
+	//同一个IP超过最大连接数
+	server, err = ListenWithOptions("tcp", "127.0.0.1:0", ProtocolFunc(NewTestCodec), HandlerFunc(func(session *Session) {
+		session.Receive()
+	}), ServerOptions{
+		MaxSessionsPerIP: 1,
+		OnSessionCreated: func(session *Session) {
+			created <- session
+		},
+	})
+	utest.IsNilNow(t, err)
+	go server.Serve()
+	addr = server.Listener().Addr().String()
+
+	client5, err := Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	<-created
+
+	client6, err := Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = client6.ReceiveContext(ctx)
+	utest.Assert(t, err != nil && err != context.DeadlineExceeded)
+
+	//第一个连接释放之后可以再次连接
+	client5.Close()
+	for {
+		server.connMutex.Lock()
+		count := len(server.ipCounts)
+		server.connMutex.Unlock()
+		if count == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	client7, err := Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	<-created
+	client7.Close()
+
+	server.Stop()
+}
+
+func ShutdownTest(t *testing.T, onShutdown func(*Session), timeout time.Duration) (int32, error) {
+	var handlerCount int32
+	created := make(chan struct{}, 10)
+	server, err := ListenWithOptions("tcp", "127.0.0.1:0", ProtocolFunc(NewTestCodec), HandlerFunc(func(session *Session) {
+		created <- struct{}{}
+		session.Receive()
+		//模拟handler在session关闭之后的收尾工作
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&handlerCount, 1)
+	}), ServerOptions{
+		OnShutdown: onShutdown,
+	})
+	utest.IsNilNow(t, err)
+	go server.Serve()
+
+	for i := 0; i < 5; i++ {
+		_, err := Dial("tcp", server.Listener().Addr().String(), ProtocolFunc(NewTestCodec), 0)
+		utest.IsNilNow(t, err)
+		<-created
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = server.Shutdown(ctx)
+	return atomic.LoadInt32(&handlerCount), err
+}
+
+func Test_Shutdown(t *testing.T) {
+	count, err := ShutdownTest(t, func(session *Session) {
+		session.Close()
+	}, time.Second)
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, count, int32(5))
+
+	count, err = ShutdownTest(t, nil, 20*time.Millisecond)
+	utest.EqualNow(t, err, context.DeadlineExceeded)
+	utest.EqualNow(t, count, int32(0))
+}
+
+func Test_ServerPanic(t *testing.T) {
+	panics := make(chan *PanicError, 1)
+	codecErrors := make(chan error, 1)
+	var reject int32
+	server, err := ListenWithOptions("tcp", "127.0.0.1:0", ProtocolFunc(func(rw io.ReadWriter) (Codec, error) {
+		if atomic.LoadInt32(&reject) == 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return NewTestCodec(rw)
+	}), HandlerFunc(func(session *Session) {
+		panic("bad handler")
+	}), ServerOptions{
+		OnPanic: func(session *Session, err *PanicError) {
+			utest.EqualNow(t, session.CloseReason(), err)
+			panics <- err
+		},
+		OnCodecError: func(conn net.Conn, err error) {
+			codecErrors <- err
+		},
+	})
+	utest.IsNilNow(t, err)
+	go server.Serve()
+	addr := server.Listener().Addr().String()
+
+	client, err := Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	panicErr := <-panics
+	utest.EqualNow(t, panicErr.Value, "bad handler")
+	utest.Assert(t, len(panicErr.Stack) > 0)
+	_, err = client.Receive()
+	utest.Assert(t, err != nil)
+
+	atomic.StoreInt32(&reject, 1)
+	_, err = Dial("tcp", addr, ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, <-codecErrors, io.ErrUnexpectedEOF)
+
+	server.Stop()
+}
+
+func Test_ServerBackPressure(t *testing.T) {
+	policies := make(chan BackPressure, 10)
+	server, err := ListenWithOptions("tcp", "127.0.0.1:0", ProtocolFunc(NewTestCodec), HandlerFunc(func(session *Session) {
+		policies <- session.backPressure
+		session.Receive()
+	}), ServerOptions{
+		SendChanSize: 1,
+		BackPressure: BackPressureDropNewest,
+	})
+	utest.IsNilNow(t, err)
+	go server.Serve()
+
+	//新建session的同时广播，背压策略需要在session放入manager之前设置
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				server.Broadcast([]byte("abc"))
+			}
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		client, err := Dial("tcp", server.Listener().Addr().String(), ProtocolFunc(NewTestCodec), 0)
+		utest.IsNilNow(t, err)
+		defer client.Close()
+		utest.EqualNow(t, <-policies, BackPressureDropNewest)
+	}
+	close(stop)
+	<-done
+	server.Stop()
+}
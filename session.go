@@ -1,53 +1,84 @@
 package link
 
 import (
+	"context"
 	"errors"
+	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 //session关闭，session阻塞的错误
 var SessionClosedError = errors.New("Session Closed")
 var SessionBlockedError = errors.New("Session Blocked")
 
+//CloseWithDrain超时，发送队列中还有没发送完的消息
+var SessionDrainTimeoutError = errors.New("Session Drain Timeout")
+
 //全局的sessionid
 var globalSessionId uint64
 
+//用于立即中断阻塞中读写的deadline
+var aLongTimeAgo = time.Unix(1, 0)
+
 //session类型
 type Session struct {
 	id        uint64           //当前的id
 	codec     Codec            //Codec接口
 	manager   *Manager         //session管理器
 	sendChan  chan interface{} //发送的chan
+	drainChan chan struct{}    //通知sendLoop发送完剩余的消息
+	stopChan  chan struct{}    //通知等待发送队列的Send放弃发送
+	drainFlag int32            //是否已经调用过CloseWithDrain
+	draining  bool             //是否正在等待发送队列清空，受sendMutex保护
 	recvMutex sync.Mutex       //接收锁
 	sendMutex sync.RWMutex     //发送锁
 
-	closeFlag          int32      //关闭标识
-	closeChan          chan int   //关闭chan
-	closeMutex         sync.Mutex //关闭锁
+	closeFlag          int32        //关闭标识
+	closeChan          chan int     //关闭chan
+	closeReason        atomic.Value //关闭原因，保存closeReason
+	closeMutex         sync.Mutex   //关闭锁
 	firstCloseCallback *closeCallback
 	lastCloseCallback  *closeCallback
 
+	backPressure BackPressure            //发送队列满时的处理策略
+	blockTimeout time.Duration           //BackPressureBlock的等待时间
+	dropCounts   [backPressureNum]uint64 //各种策略下丢弃的消息数量
+
+	keepalive *Keepalive //心跳设置
+	lastRecv  int64      //最后一次收到消息的时间，UnixNano
+
+	groupMutex sync.Mutex   //分组的锁
+	groups     []Membership //session所在的分组
+
 	State interface{} //状态
 }
 
 //新建一个session
 func NewSession(codec Codec, sendChanSize int) *Session {
-	return newSession(nil, codec, sendChanSize)
+	return newSession(nil, codec, sendChanSize, BackPressureClose, 0)
 }
 
 //新建一个session
-func newSession(manager *Manager, codec Codec, sendChanSize int) *Session {
+//背压策略在session可以被其它goroutine访问之前设置
+func newSession(manager *Manager, codec Codec, sendChanSize int, policy BackPressure, blockTimeout time.Duration) *Session {
 	session := &Session{
-		codec:     codec,
-		manager:   manager,
-		closeChan: make(chan int),
-		id:        atomic.AddUint64(&globalSessionId, 1),
+		codec:        codec,
+		manager:      manager,
+		closeChan:    make(chan int),
+		id:           atomic.AddUint64(&globalSessionId, 1),
+		lastRecv:     time.Now().UnixNano(),
+		backPressure: policy,
+		blockTimeout: blockTimeout,
 	}
 	if sendChanSize > 0 {
 		session.sendChan = make(chan interface{}, sendChanSize)
+		session.drainChan = make(chan struct{})
+		session.stopChan = make(chan struct{})
 		go session.sendLoop()
 	}
+	session.notifyCreate()
 	return session
 }
 
@@ -61,10 +92,21 @@ func (session *Session) IsClosed() bool {
 	return atomic.LoadInt32(&session.closeFlag) == 1
 }
 
-//关闭session
+//关闭原因的包装，atomic.Value不能保存nil
+type closeReason struct {
+	err error
+}
+
+//关闭session，关闭原因为SessionClosedError
 func (session *Session) Close() error {
+	return session.CloseWithReason(SessionClosedError)
+}
+
+//关闭session并记录关闭原因，关闭原因会传给关闭时的回调函数
+func (session *Session) CloseWithReason(reason error) error {
 	//关闭session
 	if atomic.CompareAndSwapInt32(&session.closeFlag, 0, 1) {
+		session.closeReason.Store(closeReason{reason})
 		close(session.closeChan)
 
 		if session.sendChan != nil {
@@ -81,6 +123,8 @@ func (session *Session) Close() error {
 		err := session.codec.Close()
 		//删除当前session
 		go func() {
+			session.notifyClose(reason)
+
 			//关闭的回调函数
 			session.invokeCloseCallbacks()
 
@@ -94,6 +138,56 @@ func (session *Session) Close() error {
 	return SessionClosedError
 }
 
+//获取关闭原因，session没有关闭时返回nil
+//读写出错导致的关闭返回对应的错误，调用Close关闭的返回SessionClosedError
+func (session *Session) CloseReason() error {
+	if reason, ok := session.closeReason.Load().(closeReason); ok {
+		return reason.err
+	}
+	return nil
+}
+
+//停止接收新的消息，等发送队列中剩余的消息发送完之后再关闭session
+//超过timeout还没发送完就用SessionDrainTimeoutError关闭session并返回这个错误，timeout小于等于0表示一直等待
+//发送出错导致session关闭时返回关闭原因，同步发送模式下等同于Close
+func (session *Session) CloseWithDrain(timeout time.Duration) error {
+	if session.sendChan == nil {
+		return session.Close()
+	}
+	if session.IsClosed() || !atomic.CompareAndSwapInt32(&session.drainFlag, 0, 1) {
+		return SessionClosedError
+	}
+
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	//等待发送队列的Send持有sendMutex的读锁，先让它们放弃发送再加写锁
+	close(session.stopChan)
+	session.sendMutex.Lock()
+	if session.IsClosed() {
+		session.sendMutex.Unlock()
+		return SessionClosedError
+	}
+	session.draining = true
+	close(session.drainChan)
+	session.sendMutex.Unlock()
+
+	select {
+	case <-session.closeChan: //sendLoop发送完之后会用SessionClosedError关闭session
+		if reason := session.CloseReason(); reason != SessionClosedError {
+			return reason
+		}
+		return nil
+	case <-timeoutChan:
+		session.CloseWithReason(SessionDrainTimeoutError)
+		return SessionDrainTimeoutError
+	}
+}
+
 //获取当前的Codec
 func (session *Session) Codec() Codec {
 	return session.codec
@@ -104,26 +198,148 @@ func (session *Session) Receive() (interface{}, error) {
 	session.recvMutex.Lock()
 	defer session.recvMutex.Unlock()
 
-	msg, err := session.codec.Receive()
-	if err != nil {
-		session.Close()
+	for {
+		msg, err := session.codec.Receive()
+		if err != nil {
+			session.notifyReceiveError(err)
+			session.CloseWithReason(err)
+			return msg, err
+		}
+		//心跳消息不返回给调用者
+		if !session.received(msg) {
+			return msg, nil
+		}
+	}
+}
+
+//接收数据，ctx结束时中断接收并返回ctx的错误
+//Codec实现了DeadlineCodec并且中断时还没有读取任何数据的话session可以继续使用，否则会关闭session
+func (session *Session) ReceiveContext(ctx context.Context) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	session.recvMutex.Lock()
+	defer session.recvMutex.Unlock()
+
+	var setDeadline func(time.Time) error
+	if dc, ok := session.codec.(DeadlineCodec); ok {
+		setDeadline = dc.SetReadDeadline
+	}
+	stop, byDeadline := session.watchContext(ctx, setDeadline)
+	defer stop()
+	for {
+		msg, err := session.codec.Receive()
+		if err != nil {
+			ctxErr := contextErr(ctx, byDeadline)
+			//通过deadline中断并且还没有读取任何数据的时候，session还可以继续使用
+			if ctxErr != nil && byDeadline && errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, ctxErr
+			}
+			//被ctx中断时返回ctx的错误
+			if ctxErr != nil {
+				err = ctxErr
+			}
+			session.notifyReceiveError(err)
+			session.CloseWithReason(err)
+			return msg, err
+		}
+		if !session.received(msg) {
+			return msg, nil
+		}
+	}
+}
+
+//监视ctx，在ctx结束时中断阻塞中的读写
+//Codec支持设置超时的时候通过deadline中断，否则只能关闭session
+//返回的函数用于结束监视并清除deadline，byDeadline表示是否通过deadline中断
+func (session *Session) watchContext(ctx context.Context, setDeadline func(time.Time) error) (stop func(), byDeadline bool) {
+	if setDeadline != nil {
+		//底层的连接不支持设置超时的时候只能关闭session
+		deadline, _ := ctx.Deadline()
+		if setDeadline(deadline) != nil {
+			setDeadline = nil
+		}
+	}
+	//永远不会结束的ctx不需要监视
+	if ctx.Done() == nil {
+		return func() {}, setDeadline != nil
+	}
+
+	stopChan := make(chan struct{})
+	exitChan := make(chan struct{})
+	go func() {
+		defer close(exitChan)
+		select {
+		case <-ctx.Done():
+			if setDeadline != nil {
+				setDeadline(aLongTimeAgo)
+			} else {
+				session.CloseWithReason(ctx.Err())
+			}
+		case <-stopChan:
+		}
+	}()
+	return func() {
+		close(stopChan)
+		<-exitChan
+		if setDeadline != nil {
+			setDeadline(time.Time{})
+		}
+	}, setDeadline != nil
+}
+
+//获取ctx的错误，底层连接的deadline可能比ctx的定时器先到，这时ctx.Err()还是nil
+func contextErr(ctx context.Context, byDeadline bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); byDeadline && ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
 	}
-	return msg, err
+	return nil
 }
 
 //发送loop
 func (session *Session) sendLoop() {
-	defer session.Close()
+	var err error = SessionClosedError
+	defer func() {
+		session.CloseWithReason(err)
+	}()
 	for {
 		select {
 
 		case msg, ok := <-session.sendChan:
 			//接收消息失败，或者发送失败就返回
-			if !ok || session.codec.Send(msg) != nil {
+			if !ok {
+				return
+			}
+			if err = session.codec.Send(msg); err != nil {
+				session.notifySendError(err)
 				return
 			}
 		case <-session.closeChan: //关闭chan
 			return
+		case <-session.drainChan:
+			//把队列中剩余的消息发送完再关闭
+			for {
+				select {
+				case msg, ok := <-session.sendChan:
+					//超时之后Close会关闭sendChan
+					if !ok {
+						return
+					}
+					if err = session.codec.Send(msg); err != nil {
+						session.notifySendError(err)
+						return
+					}
+				case <-session.closeChan:
+					return
+				default:
+					err = SessionClosedError
+					return
+				}
+			}
 		}
 	}
 }
@@ -141,25 +357,102 @@ func (session *Session) Send(msg interface{}) error {
 		//直接发送
 		err := session.codec.Send(msg)
 		if err != nil {
-			session.Close()
+			session.notifySendError(err)
+			session.CloseWithReason(err)
 		}
 		return err
 	}
+	return session.sendAsync(context.Background(), msg)
+}
 
+//放入异步发送队列，队列满时按照背压策略处理
+//ctx只用于BackPressureBlock的等待，ctx结束时放弃发送并返回ctx的错误，不会关闭session
+func (session *Session) sendAsync(ctx context.Context, msg interface{}) error {
 	session.sendMutex.RLock()
-	if session.IsClosed() {
+	if session.IsClosed() || session.draining {
 		session.sendMutex.RUnlock()
 		return SessionClosedError
 	}
-	//把数据写入，否则报阻塞错误
+	//把数据写入，否则按照背压策略处理
 	select {
 	case session.sendChan <- msg:
 		session.sendMutex.RUnlock()
 		return nil
 	default:
+	}
+	session.sendMutex.RUnlock()
+
+	//观察者可能在OnBlocked中关闭session，Close需要sendMutex的写锁，所以通知时不能持有读锁
+	session.notifyBlocked(msg)
+
+	session.sendMutex.RLock()
+	if session.IsClosed() || session.draining {
+		session.sendMutex.RUnlock()
+		return SessionClosedError
+	}
+	//通知期间队列可能已经空出位置
+	select {
+	case session.sendChan <- msg:
 		session.sendMutex.RUnlock()
-		session.Close()
-		return SessionBlockedError
+		return nil
+	default:
+	}
+	err := session.sendBlocked(ctx, msg)
+	session.sendMutex.RUnlock()
+	if err == SessionBlockedError {
+		session.CloseWithReason(err)
+	}
+	return err
+}
+
+//发送数据，ctx结束时放弃发送
+//同步发送时中断发送并关闭session，异步发送时在发送队列满的情况下等待直到ctx结束
+func (session *Session) SendContext(ctx context.Context, msg interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if session.sendChan == nil {
+		if session.IsClosed() {
+			return SessionClosedError
+		}
+
+		session.sendMutex.Lock()
+		defer session.sendMutex.Unlock()
+
+		var setDeadline func(time.Time) error
+		if dc, ok := session.codec.(DeadlineCodec); ok {
+			setDeadline = dc.SetWriteDeadline
+		}
+		//写了一部分数据之后中断会破坏数据流，所以发送被中断时总是关闭session
+		stop, byDeadline := session.watchContext(ctx, setDeadline)
+		err := session.codec.Send(msg)
+		stop()
+		if err != nil {
+			if ctxErr := contextErr(ctx, byDeadline); ctxErr != nil {
+				err = ctxErr
+			}
+			session.notifySendError(err)
+			session.CloseWithReason(err)
+		}
+		return err
+	}
+
+	session.sendMutex.RLock()
+	defer session.sendMutex.RUnlock()
+	if session.IsClosed() || session.draining {
+		return SessionClosedError
+	}
+	//Close会先关闭closeChan再加写锁，CloseWithDrain会先关闭stopChan再加写锁，所以这里可以持有读锁等待
+	select {
+	case session.sendChan <- msg:
+		return nil
+	case <-session.closeChan:
+		return SessionClosedError
+	case <-session.stopChan:
+		return SessionClosedError
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -167,12 +460,12 @@ func (session *Session) Send(msg interface{}) error {
 type closeCallback struct {
 	Handler interface{}
 	Key     interface{}
-	Func    func()
+	Func    func(reason error)
 	Next    *closeCallback
 }
 
-//新增时的回调
-func (session *Session) AddCloseCallback(handler, key interface{}, callback func()) {
+//新增时的回调，回调函数的参数是session的关闭原因
+func (session *Session) AddCloseCallback(handler, key interface{}, callback func(reason error)) {
 	if session.IsClosed() {
 		return
 	}
@@ -220,7 +513,8 @@ func (session *Session) invokeCloseCallbacks() {
 	session.closeMutex.Lock()
 	defer session.closeMutex.Unlock()
 
+	reason := session.CloseReason()
 	for callback := session.firstCloseCallback; callback != nil; callback = callback.Next {
-		callback.Func()
+		callback.Func(reason)
 	}
 }
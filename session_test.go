@@ -2,9 +2,12 @@ package link
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"io"
 	"math/rand"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -121,12 +124,12 @@ func BytesTest(t *testing.T, session *Session) {
 }
 
 func Test_CloseCallback(t *testing.T) {
-	session := newSession(nil, nil, 0)
+	session := newSession(nil, nil, 0, BackPressureClose, 0)
 
 	c := make(chan int, 10)
 	for i := 0; i < 10; i++ {
 		func(n int) {
-			callback := func() {
+			callback := func(error) {
 				c <- n
 			}
 			session.AddCloseCallback(nil, n, callback)
@@ -143,6 +146,21 @@ func Test_CloseCallback(t *testing.T) {
 	}
 }
 
+func Test_CloseReason(t *testing.T) {
+	session := newSession(nil, &BlockingCodec{}, 0, BackPressureClose, 0)
+	utest.IsNilNow(t, session.CloseReason())
+
+	reasons := make(chan error, 1)
+	session.AddCloseCallback(nil, nil, func(reason error) {
+		reasons <- reason
+	})
+	reason := errors.New("kicked")
+	utest.IsNilNow(t, session.CloseWithReason(reason))
+	utest.EqualNow(t, session.CloseWithReason(io.EOF), SessionClosedError)
+	utest.EqualNow(t, session.CloseReason(), reason)
+	utest.EqualNow(t, <-reasons, reason)
+}
+
 func Test_Sync(t *testing.T) {
 	SessionTest(t, 0, BytesTest)
 }
@@ -151,6 +169,182 @@ func Test_Async(t *testing.T) {
 	SessionTest(t, 1024, BytesTest)
 }
 
+func Test_ReceiveContext(t *testing.T) {
+	server, err := Listen("tcp", "0.0.0.0:0", ProtocolFunc(NewTestCodec), 0, HandlerFunc(func(session *Session) {
+		session.Receive()
+	}))
+	utest.IsNilNow(t, err)
+	go server.Serve()
+
+	session, err := Dial("tcp", server.Listener().Addr().String(), ProtocolFunc(NewTestCodec), 0)
+	utest.IsNilNow(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = session.ReceiveContext(ctx)
+	utest.EqualNow(t, err, context.DeadlineExceeded)
+	utest.Assert(t, session.IsClosed())
+
+	server.Stop()
+}
+
+func Test_SendContext(t *testing.T) {
+	//同步发送，Codec不支持超时的时候关闭session来中断发送
+	conn1, conn2 := net.Pipe()
+	defer conn2.Close()
+	codec, _ := NewTestCodec(conn1)
+	session := NewSession(codec, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	utest.EqualNow(t, session.SendContext(ctx, []byte{1}), context.DeadlineExceeded)
+	utest.Assert(t, session.IsClosed())
+	utest.EqualNow(t, session.CloseReason(), context.DeadlineExceeded)
+
+	//异步发送，发送队列满的时候等待，ctx结束时返回ctx的错误，session可以继续使用
+	blocking := newBlockingCodec()
+	session = NewSession(blocking, 1)
+	utest.IsNilNow(t, session.Send(1))
+	for len(session.sendChan) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	utest.IsNilNow(t, session.Send(2))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	utest.EqualNow(t, session.SendContext(ctx, 3), context.DeadlineExceeded)
+	utest.Assert(t, !session.IsClosed())
+	utest.EqualNow(t, session.DropCount(BackPressureClose), uint64(0))
+
+	//队列空出位置之后可以继续发送
+	close(blocking.release)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	utest.IsNilNow(t, session.SendContext(ctx, 4))
+	utest.EqualNow(t, <-blocking.sent, 1)
+	utest.EqualNow(t, <-blocking.sent, 2)
+	utest.EqualNow(t, <-blocking.sent, 4)
+	session.Close()
+
+	//已经结束的ctx不会发送
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	session = NewSession(newBlockingCodec(), 0)
+	utest.EqualNow(t, session.SendContext(ctx, 5), context.Canceled)
+	utest.Assert(t, !session.IsClosed())
+}
+
+//close(release)之前Send和Receive一直阻塞，发送的消息放入sent
+type BlockingCodec struct {
+	release chan struct{}
+	sent    chan interface{}
+}
+
+func newBlockingCodec() *BlockingCodec {
+	return &BlockingCodec{make(chan struct{}), make(chan interface{}, 100)}
+}
+
+func (c *BlockingCodec) Send(msg interface{}) error {
+	<-c.release
+	c.sent <- msg
+	return nil
+}
+
+func (c *BlockingCodec) Receive() (interface{}, error) {
+	<-c.release
+	return nil, io.EOF
+}
+
+func (c *BlockingCodec) Close() error {
+	return nil
+}
+
+func Test_BackPressure(t *testing.T) {
+	for _, policy := range []BackPressure{BackPressureDropNewest, BackPressureDropOldest} {
+		codec := newBlockingCodec()
+		session := NewSession(codec, 1)
+		session.SetBackPressure(policy, 0)
+
+		//第一条消息被sendLoop取出后阻塞在Codec.Send上
+		utest.IsNilNow(t, session.Send(1))
+		for len(session.sendChan) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		utest.IsNilNow(t, session.Send(2))
+		utest.IsNilNow(t, session.Send(3))
+		utest.EqualNow(t, session.DropCount(policy), uint64(1))
+		utest.Assert(t, !session.IsClosed())
+
+		close(codec.release)
+		utest.EqualNow(t, <-codec.sent, 1)
+		if policy == BackPressureDropNewest {
+			utest.EqualNow(t, <-codec.sent, 2)
+		} else {
+			utest.EqualNow(t, <-codec.sent, 3)
+		}
+		session.Close()
+	}
+
+	codec := newBlockingCodec()
+	session := NewSession(codec, 1)
+	session.SetBackPressure(BackPressureBlock, 10*time.Millisecond)
+	utest.IsNilNow(t, session.Send(1))
+	for len(session.sendChan) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	utest.IsNilNow(t, session.Send(2))
+	utest.EqualNow(t, session.Send(3), SessionBlockedError)
+	utest.EqualNow(t, session.DropCount(BackPressureBlock), uint64(1))
+	utest.Assert(t, session.IsClosed())
+	close(codec.release)
+}
+
+func Test_CloseWithDrain(t *testing.T) {
+	codec := newBlockingCodec()
+	session := NewSession(codec, 10)
+	for i := 0; i < 5; i++ {
+		utest.IsNilNow(t, session.Send(i))
+	}
+	close(codec.release)
+	utest.IsNilNow(t, session.CloseWithDrain(time.Second))
+	utest.Assert(t, session.IsClosed())
+	utest.EqualNow(t, session.Send(5), SessionClosedError)
+	for i := 0; i < 5; i++ {
+		utest.EqualNow(t, <-codec.sent, i)
+	}
+
+	codec = newBlockingCodec()
+	session = NewSession(codec, 10)
+	utest.IsNilNow(t, session.Send(0))
+	utest.EqualNow(t, session.CloseWithDrain(10*time.Millisecond), SessionDrainTimeoutError)
+	utest.Assert(t, session.IsClosed())
+	utest.EqualNow(t, session.CloseReason(), SessionDrainTimeoutError)
+	close(codec.release)
+
+	//等待发送队列的Send不会阻塞CloseWithDrain，超时之后sendLoop不会发送nil
+	codec = newBlockingCodec()
+	session = NewSession(codec, 1)
+	session.SetBackPressure(BackPressureBlock, 0)
+	utest.IsNilNow(t, session.Send(1))
+	for len(session.sendChan) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	utest.IsNilNow(t, session.Send(2))
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- session.Send(3)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	utest.EqualNow(t, session.CloseWithDrain(20*time.Millisecond), SessionDrainTimeoutError)
+	utest.Assert(t, time.Since(start) < time.Second)
+	utest.EqualNow(t, <-blocked, SessionClosedError)
+	close(codec.release)
+	time.Sleep(10 * time.Millisecond)
+	for len(codec.sent) > 0 {
+		utest.Assert(t, <-codec.sent != nil)
+	}
+}
+
 func Test_Channel(t *testing.T) {
 	waitTestDone := make(chan struct{})
 
@@ -0,0 +1,142 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+//收到或者要发送的消息类型和TypedSession的类型参数不匹配
+var MessageTypeError = errors.New("Message Type Mismatch")
+
+//收到In类型消息，发送Out类型消息的Codec
+type TypedCodec[In, Out any] interface {
+	Receive() (In, error)
+	Send(Out) error
+	Close() error
+}
+
+//返回TypedCodec的协议函数，可以当作Protocol用于Server和Dial
+type TypedProtocolFunc[In, Out any] func(rw io.ReadWriter) (TypedCodec[In, Out], error)
+
+func (pf TypedProtocolFunc[In, Out]) NewCodec(rw io.ReadWriter) (Codec, error) {
+	codec, err := pf(rw)
+	if err != nil {
+		return nil, err
+	}
+	return typedCodec[In, Out]{codec}, nil
+}
+
+//把TypedCodec包装成Codec
+type typedCodec[In, Out any] struct {
+	codec TypedCodec[In, Out]
+}
+
+func (c typedCodec[In, Out]) Receive() (interface{}, error) {
+	msg, err := c.codec.Receive()
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (c typedCodec[In, Out]) Send(msg interface{}) error {
+	out, ok := msg.(Out)
+	if !ok {
+		return MessageTypeError
+	}
+	return c.codec.Send(out)
+}
+
+func (c typedCodec[In, Out]) Close() error {
+	return c.codec.Close()
+}
+
+//收到In类型消息，发送Out类型消息的Session
+//收发和关闭都由内部的Session完成，所以和Session的语义完全一致
+type TypedSession[In, Out any] struct {
+	session *Session
+}
+
+//新建一个TypedSession
+func NewTypedSession[In, Out any](codec TypedCodec[In, Out], sendChanSize int) *TypedSession[In, Out] {
+	return Typed[In, Out](NewSession(typedCodec[In, Out]{codec}, sendChanSize))
+}
+
+//把已有的session包装成TypedSession，收到的消息类型不匹配时Receive返回MessageTypeError
+func Typed[In, Out any](session *Session) *TypedSession[In, Out] {
+	return &TypedSession[In, Out]{session}
+}
+
+//获取内部的session
+func (typed *TypedSession[In, Out]) Session() *Session {
+	return typed.session
+}
+
+//获取id
+func (typed *TypedSession[In, Out]) ID() uint64 {
+	return typed.session.ID()
+}
+
+//是否关闭
+func (typed *TypedSession[In, Out]) IsClosed() bool {
+	return typed.session.IsClosed()
+}
+
+//关闭session
+func (typed *TypedSession[In, Out]) Close() error {
+	return typed.session.Close()
+}
+
+//关闭session并记录关闭原因
+func (typed *TypedSession[In, Out]) CloseWithReason(reason error) error {
+	return typed.session.CloseWithReason(reason)
+}
+
+//获取关闭原因
+func (typed *TypedSession[In, Out]) CloseReason() error {
+	return typed.session.CloseReason()
+}
+
+//接收数据
+func (typed *TypedSession[In, Out]) Receive() (In, error) {
+	return typedMessage[In](typed.session.Receive())
+}
+
+//接收数据，ctx结束时中断接收并关闭session
+func (typed *TypedSession[In, Out]) ReceiveContext(ctx context.Context) (In, error) {
+	return typedMessage[In](typed.session.ReceiveContext(ctx))
+}
+
+//发送数据
+func (typed *TypedSession[In, Out]) Send(msg Out) error {
+	return typed.session.Send(msg)
+}
+
+//发送数据，ctx结束时放弃发送
+func (typed *TypedSession[In, Out]) SendContext(ctx context.Context, msg Out) error {
+	return typed.session.SendContext(ctx, msg)
+}
+
+//新增关闭时的回调
+func (typed *TypedSession[In, Out]) AddCloseCallback(handler, key interface{}, callback func(reason error)) {
+	typed.session.AddCloseCallback(handler, key, callback)
+}
+
+//删除关闭时的回调
+func (typed *TypedSession[In, Out]) RemoveCloseCallback(handler, key interface{}) {
+	typed.session.RemoveCloseCallback(handler, key)
+}
+
+//把收到的消息转换成In类型
+func typedMessage[In any](msg interface{}, err error) (In, error) {
+	var in In
+	if err != nil {
+		return in, err
+	}
+	in, ok := msg.(In)
+	if !ok {
+		return in, MessageTypeError
+	}
+	return in, nil
+}
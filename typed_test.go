@@ -0,0 +1,73 @@
+package link
+
+import (
+	"io"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+type StringCodec struct {
+	base Codec
+}
+
+func NewStringCodec(rw io.ReadWriter) (TypedCodec[string, string], error) {
+	base, err := NewTestCodec(rw)
+	return StringCodec{base}, err
+}
+
+func (c StringCodec) Receive() (string, error) {
+	msg, err := c.base.Receive()
+	if err != nil {
+		return "", err
+	}
+	return string(msg.([]byte)), nil
+}
+
+func (c StringCodec) Send(msg string) error {
+	return c.base.Send([]byte(msg))
+}
+
+func (c StringCodec) Close() error {
+	return c.base.Close()
+}
+
+func Test_TypedSession(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	codec1, _ := NewStringCodec(conn1)
+	codec2, _ := TypedProtocolFunc[string, string](NewStringCodec).NewCodec(conn2)
+
+	session1 := NewTypedSession[string, string](codec1, 10)
+	session2 := Typed[string, string](NewSession(codec2, 10))
+
+	utest.IsNilNow(t, session1.Send("hello"))
+	msg, err := session2.Receive()
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, msg, "hello")
+
+	//类型不匹配的消息
+	utest.IsNilNow(t, session2.Session().Send(123))
+	for !session2.IsClosed() {
+		runtime.Gosched()
+	}
+	utest.EqualNow(t, session2.CloseReason(), MessageTypeError)
+	_, err = session1.Receive()
+	utest.Assert(t, err != nil)
+	session1.Close()
+}
+
+func Test_TypedChannel(t *testing.T) {
+	channel := NewTypedChannel[uint64]()
+	session := newSession(nil, &BlockingCodec{}, 0, BackPressureClose, 0)
+	channel.Put(session.ID(), session)
+	utest.EqualNow(t, channel.Get(session.ID()), session)
+	utest.EqualNow(t, channel.Len(), 1)
+
+	session.Close()
+	for channel.Len() != 0 {
+		runtime.Gosched()
+	}
+	utest.EqualNow(t, channel.Get(session.ID()), nil)
+}